@@ -0,0 +1,521 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"sigs.k8s.io/yaml"
+
+	"managedfields/pkg/policy"
+	"managedfields/pkg/render"
+	"managedfields/pkg/report"
+	"managedfields/pkg/sarif"
+	"managedfields/pkg/scan"
+	"managedfields/pkg/utils"
+)
+
+func newRootCommand(streams genericiooptions.IOStreams) *cobra.Command {
+	o := newOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "kubectl managed-fields",
+		Short:        "Inspect the managed fields of Kubernetes objects",
+		SilenceUsage: true,
+	}
+	cmd.SetOut(streams.Out)
+	cmd.SetErr(streams.ErrOut)
+	o.addFlags(cmd)
+
+	cmd.AddCommand(
+		newPathsCommand(o),
+		newOwnersCommand(o),
+		newConflictsCommand(o),
+		newTimelineCommand(o),
+		newDiffCommand(o),
+		newLintCommand(o),
+		newScanCommand(o),
+	)
+
+	return cmd
+}
+
+func newPathsCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "paths [-f FILENAME | TYPE NAME]",
+		Short: "List the paths of the fields of every manager",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			objects, err := o.objects(args)
+			if err != nil {
+				return err
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "OBJECT\tMANAGER\tOPERATION\tSUBRESOURCE\tPATH")
+			for _, object := range objects {
+				for idx, managedField := range object.GetManagedFields() {
+					paths, err := utils.ParseFieldsV1(managedField.FieldsV1)
+					if err != nil {
+						return fmt.Errorf("%s managedFields[%d]: %w", objectName(object), idx, err)
+					}
+					for _, path := range paths {
+						if path.Human() == "" {
+							continue
+						}
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", objectName(object), managedField.Manager,
+							managedField.Operation, orNone(managedField.Subresource), path.Human())
+					}
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newOwnersCommand(o *options) *cobra.Command {
+	var output string
+	var color bool
+
+	cmd := &cobra.Command{
+		Use:   "owners [-f FILENAME | TYPE NAME]",
+		Short: "List the managers owning every field",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			objects, err := o.objects(args)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "tree", "table":
+				return renderOwners(o, objects, output, render.Options{Color: color})
+			case "":
+			default:
+				return fmt.Errorf("unknown output %q, must be tree or table", output)
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "OBJECT\tPATH\tMANAGERS")
+			for _, object := range objects {
+				owners, err := fieldOwners(object.GetManagedFields())
+				if err != nil {
+					return fmt.Errorf("%s: %w", objectName(object), err)
+				}
+
+				paths := make([]string, 0, len(owners))
+				for path := range owners {
+					paths = append(paths, path)
+				}
+				sort.Strings(paths)
+
+				for _, path := range paths {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", objectName(object), path, strings.Join(owners[path], ","))
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format, tree or table of every field with its owners, operation and time")
+	cmd.Flags().BoolVar(&color, "color", false, "Render every manager with its own color")
+
+	return cmd
+}
+
+// Helper function rendering the field tree or table of every object
+func renderOwners(o *options, objects []*unstructured.Unstructured, output string, renderOptions render.Options) error {
+	for idx, object := range objects {
+		if idx > 0 {
+			fmt.Fprintln(o.Out)
+		}
+		fmt.Fprintln(o.Out, objectName(object))
+
+		var err error
+		if output == "tree" {
+			err = render.Tree(o.Out, object.GetManagedFields(), renderOptions)
+		} else {
+			err = render.Table(o.Out, object.GetManagedFields(), renderOptions)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", objectName(object), err)
+		}
+	}
+	return nil
+}
+
+func newConflictsCommand(o *options) *cobra.Command {
+	var manager string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "conflicts --manager MANAGER [-f FILENAME | TYPE NAME]",
+		Short: "List the fields of a manager also written by other managers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			objects, err := o.objects(args)
+			if err != nil {
+				return err
+			}
+			opts, err := o.detectOptions()
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "json", "yaml":
+				return printReport(o, objects, manager, output, opts)
+			case "":
+			default:
+				return fmt.Errorf("unknown output %q, must be json or yaml", output)
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "OBJECT\tEXTERNAL MANAGER\tOPERATION\tTIME\tOVERWRITTEN\tPATH")
+			for _, object := range objects {
+				conflicts, err := utils.DetectFieldConflictsInObject(manager, object, opts...)
+				warnEntryErrors(o, object, err)
+				for _, conflict := range conflicts {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", objectName(object), conflict.ExternalManager,
+						conflict.Operation, formatTime(conflict.Time), conflict.Overwritten, conflict.Path.Human())
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&manager, "manager", "", "The manager the fields belong to")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format, json or yaml conflict report")
+	_ = cmd.MarkFlagRequired("manager")
+
+	return cmd
+}
+
+// Helper function warning about the managed fields entries of the object that could not be analyzed
+func warnEntryErrors(o *options, object *unstructured.Unstructured, err error) {
+	for _, entryError := range utils.EntryErrors(err) {
+		fmt.Fprintf(o.ErrOut, "Warning: %s: %v\n", objectName(object), entryError)
+	}
+}
+
+// Helper function printing the conflict report of the objects
+func printReport(o *options, objects []*unstructured.Unstructured, manager, output string, opts []utils.DetectOption) error {
+	conflictReport := report.New()
+	for _, object := range objects {
+		conflicts, err := utils.DetectFieldConflictsInObject(manager, object, opts...)
+		warnEntryErrors(o, object, err)
+		objectReport, err := report.NewObject(object, manager, conflicts)
+		if err != nil {
+			return err
+		}
+		conflictReport.Objects = append(conflictReport.Objects, objectReport)
+	}
+
+	marshal := report.MarshalYAML
+	if output == "json" {
+		marshal = report.MarshalJSON
+	}
+
+	data, err := marshal(conflictReport)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(o.Out, strings.TrimSuffix(string(data), "\n"))
+	return err
+}
+
+func newTimelineCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "timeline [-f FILENAME | TYPE NAME]",
+		Short: "List the managed fields entries sorted by time",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			objects, err := o.objects(args)
+			if err != nil {
+				return err
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "OBJECT\tTIME\tMANAGER\tOPERATION\tSUBRESOURCE\tAPIVERSION\tFIELDS")
+			for _, object := range objects {
+				managedFields := object.GetManagedFields()
+				sort.SliceStable(managedFields, func(i, j int) bool {
+					return timeOf(managedFields[i]).Before(timeOf(managedFields[j]))
+				})
+
+				for _, managedField := range managedFields {
+					paths, _ := utils.ParseFieldsV1(managedField.FieldsV1)
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", objectName(object), formatTime(managedField.Time),
+						managedField.Manager, managedField.Operation, orNone(managedField.Subresource),
+						managedField.APIVersion, len(paths))
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newDiffCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff BEFORE AFTER",
+		Short: "Compare the managed fields between two files of the same objects, e.g. backups",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			before, err := o.objectsOfFile(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := o.objectsOfFile(args[1])
+			if err != nil {
+				return err
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "OBJECT\tMANAGER\tCHANGE\tDETAIL")
+			for _, object := range after {
+				previous, found := before[objectName(object)]
+				if !found {
+					continue
+				}
+
+				diff, err := utils.DiffManagedFields(previous.GetManagedFields(), object.GetManagedFields())
+				if err != nil {
+					return fmt.Errorf("%s: %w", objectName(object), err)
+				}
+
+				for _, manager := range diff.Managers {
+					for _, path := range manager.Gained {
+						fmt.Fprintf(w, "%s\t%s\tgained\t%s\n", objectName(object), manager.Manager, path.Human())
+					}
+					for _, path := range manager.Lost {
+						fmt.Fprintf(w, "%s\t%s\tlost\t%s\n", objectName(object), manager.Manager, path.Human())
+					}
+				}
+				for _, entry := range diff.Added {
+					fmt.Fprintf(w, "%s\t%s\tadded\t%s\n", objectName(object), entry.Manager, entryName(entry))
+				}
+				for _, entry := range diff.Removed {
+					fmt.Fprintf(w, "%s\t%s\tremoved\t%s\n", objectName(object), entry.Manager, entryName(entry))
+				}
+				for _, change := range diff.TimeChanges {
+					fmt.Fprintf(w, "%s\t%s\ttime\t%s -> %s\n", objectName(object), change.Manager,
+						formatTime(change.Before), formatTime(change.After))
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newLintCommand(o *options) *cobra.Command {
+	var policyFile string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "lint --policy POLICY -f FILENAME",
+		Short: "Check the managed fields of manifest files against an ownership policy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(o.filenames) == 0 {
+				return fmt.Errorf("-f is required")
+			}
+
+			p, err := policy.ParseFile(policyFile)
+			if err != nil {
+				return err
+			}
+			opts, err := o.detectOptions()
+			if err != nil {
+				return err
+			}
+
+			log, err := sarif.LintWithOptions(p, o.filenames, opts...)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "sarif":
+				data, err := sarif.Marshal(log)
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(o.Out, string(data))
+				return err
+			case "":
+			default:
+				return fmt.Errorf("unknown output %q, must be sarif", output)
+			}
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "LOCATION\tRULE\tLEVEL\tMESSAGE")
+			for _, result := range log.Runs[0].Results {
+				location := result.Locations[0].PhysicalLocation
+				fmt.Fprintf(w, "%s:%d\t%s\t%s\t%s\n", location.ArtifactLocation.URI, location.Region.StartLine,
+					result.RuleID, result.Level, result.Message.Text)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&policyFile, "policy", "", "The ownership policy file")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format, sarif for code scanning annotations")
+	_ = cmd.MarkFlagRequired("policy")
+
+	return cmd
+}
+
+func newScanCommand(o *options) *cobra.Command {
+	scanner := &scan.Scanner{}
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "scan -f DIRECTORY [--manager MANAGER]",
+		Short: "Aggregate the conflicts of the objects of exported manifests, e.g. of a cluster backup",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(o.filenames) == 0 {
+				return fmt.Errorf("-f is required")
+			}
+
+			opts, err := o.detectOptions()
+			if err != nil {
+				return err
+			}
+			scanner.Options = opts
+
+			summary, err := scanner.Scan(cmd.Context(), o.filenames...)
+			if err != nil {
+				return err
+			}
+			for _, scanError := range summary.Errors {
+				fmt.Fprintf(o.ErrOut, "Warning: %s\n", scanError)
+			}
+
+			switch output {
+			case "json", "yaml":
+				data, err := json.Marshal(summary)
+				if err != nil {
+					return err
+				}
+				if output == "yaml" {
+					if data, err = yaml.JSONToYAML(data); err != nil {
+						return err
+					}
+				}
+				_, err = fmt.Fprintln(o.Out, strings.TrimSuffix(string(data), "\n"))
+				return err
+			case "":
+			default:
+				return fmt.Errorf("unknown output %q, must be json or yaml", output)
+			}
+
+			fmt.Fprintf(o.Out, "%d files, %d objects, %d objects with conflicts, %d conflicts\n\n",
+				summary.Files, summary.Objects, summary.ObjectsWithConflicts, summary.Conflicts)
+
+			w := newTableWriter(o)
+			fmt.Fprintln(w, "MANAGER\tCATEGORY\tOBJECTS\tCONFLICTS\tOVERWRITES")
+			for _, manager := range summary.Managers {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", manager.Manager, manager.Category,
+					manager.Objects, manager.Conflicts, manager.Overwrites)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(o.Out)
+
+			w = newTableWriter(o)
+			fmt.Fprintln(w, "KIND\tPATH\tOBJECTS\tCONFLICTS\tMANAGERS")
+			for _, kind := range summary.Kinds {
+				for _, path := range kind.Paths {
+					fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", kind.Kind, path.Path,
+						path.Objects, path.Conflicts, strings.Join(path.Managers, ","))
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&scanner.OriginalManagers, "manager", nil, "The managers the fields belong to, every manager when not set")
+	cmd.Flags().IntVar(&scanner.Workers, "workers", 0, "The number of files analyzed at once, the number of CPUs when 0")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format, json or yaml summary")
+
+	return cmd
+}
+
+// objectsOfFile returns the objects of the file by kind/namespace/name
+func (o *options) objectsOfFile(filename string) (map[string]*unstructured.Unstructured, error) {
+	fileOptions := *o
+	fileOptions.filenames = []string{filename}
+
+	objects, err := fileOptions.objects(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*unstructured.Unstructured{}
+	for _, object := range objects {
+		byName[objectName(object)] = object
+	}
+	return byName, nil
+}
+
+// Helper function returning the managers of every field path
+func fieldOwners(managedFields []metav1.ManagedFieldsEntry) (map[string][]string, error) {
+	fields, err := managerFields(managedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string][]string{}
+	for manager, paths := range fields {
+		for path := range paths {
+			owners[path] = append(owners[path], manager)
+		}
+	}
+	for path := range owners {
+		sort.Strings(owners[path])
+	}
+	return owners, nil
+}
+
+// Helper function returning the field paths of every manager
+func managerFields(managedFields []metav1.ManagedFieldsEntry) (map[string]map[string]struct{}, error) {
+	fields := map[string]map[string]struct{}{}
+	for idx, managedField := range managedFields {
+		paths, err := utils.ParseFieldsV1(managedField.FieldsV1)
+		if err != nil {
+			return nil, fmt.Errorf("managedFields[%d]: %w", idx, err)
+		}
+		if fields[managedField.Manager] == nil {
+			fields[managedField.Manager] = map[string]struct{}{}
+		}
+		for _, path := range paths {
+			if path.Human() != "" {
+				fields[managedField.Manager][path.Human()] = struct{}{}
+			}
+		}
+	}
+	return fields, nil
+}
+
+// Helper function naming an entry of a manager, with the API version
+// of Update operations, entries of other API versions are other entries
+func entryName(entry metav1.ManagedFieldsEntry) string {
+	name := string(entry.Operation)
+	if entry.Subresource != "" {
+		name += " " + entry.Subresource
+	}
+	if entry.Operation != metav1.ManagedFieldsOperationApply && entry.APIVersion != "" {
+		name += " " + entry.APIVersion
+	}
+	return name
+}
+
+func timeOf(managedField metav1.ManagedFieldsEntry) time.Time {
+	if managedField.Time == nil {
+		return time.Time{}
+	}
+	return managedField.Time.Time
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestCommands(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		args           []string
+		expectedOutput string
+	}{
+		{
+			desc: "paths",
+			args: []string{"paths", "-f", "testdata/after.yaml"},
+			expectedOutput: `
+OBJECT                    MANAGER                    OPERATION  SUBRESOURCE  PATH
+default/deployment/nginx  original-manager           Update     <none>       spec.template.spec.containers[nginx].resources.limits
+default/deployment/nginx  kubectl-client-side-apply  Update     <none>       spec.replicas
+default/deployment/nginx  kubectl-client-side-apply  Update     <none>       spec.template.spec.containers[nginx]
+default/deployment/nginx  kubectl-client-side-apply  Update     <none>       spec.template.spec.containers[nginx].image
+default/deployment/nginx  kubectl-client-side-apply  Update     <none>       spec.template.spec.containers[nginx].name
+default/deployment/nginx  kubectl-client-side-apply  Update     <none>       spec.template.spec.containers[nginx].resources.requests
+`,
+		},
+		{
+			desc: "owners",
+			args: []string{"owners", "-f", "testdata/conflicts.json"},
+			expectedOutput: `
+OBJECT                    PATH                                                     MANAGERS
+default/deployment/nginx  spec.template.spec.containers[nginx].resources.limits    original-manager
+default/deployment/nginx  spec.template.spec.containers[nginx].resources.requests  kubectl-client-side-apply,original-manager
+`,
+		},
+		{
+			desc: "owners tree",
+			args: []string{"owners", "-o", "tree", "-f", "testdata/conflicts.json"},
+			expectedOutput: `
+default/deployment/nginx
+spec
+└── template
+    └── spec
+        └── containers
+            └── [nginx]
+                └── resources
+                    ├── limits  original-manager (Update 2024-06-17T19:56:27Z)
+                    └── requests  kubectl-client-side-apply (Update 2044-06-17T19:56:27Z), original-manager (Update 2024-06-17T19:56:27Z)
+`,
+		},
+		{
+			desc: "conflicts",
+			args: []string{"conflicts", "--manager", "original-manager", "-f", "testdata/conflicts.json"},
+			expectedOutput: `
+OBJECT                    EXTERNAL MANAGER           OPERATION  TIME                  OVERWRITTEN  PATH
+default/deployment/nginx  kubectl-client-side-apply  Update     2044-06-17T19:56:27Z  true         spec.template.spec.containers[nginx].resources.requests
+`,
+		},
+		{
+			desc:           "conflicts ignored",
+			args:           []string{"conflicts", "--manager", "original-manager", "--ignore", "testdata/ignore.yaml", "-f", "testdata/conflicts.json"},
+			expectedOutput: "OBJECT  EXTERNAL MANAGER  OPERATION  TIME  OVERWRITTEN  PATH\n",
+		},
+		{
+			desc: "conflicts report",
+			args: []string{"conflicts", "--manager", "original-manager", "-o", "yaml", "-f", "testdata/conflicts.json"},
+			expectedOutput: `
+apiVersion: managedfields/v1
+kind: ConflictReport
+objects:
+- apiVersion: apps/v1
+  conflicts:
+  - apiVersion: apps/v1
+    category: human
+    externalManager: kubectl-client-side-apply
+    fieldPath: /spec/template/spec/containers/[{"name":"nginx"}]/resources/requests
+    kind: update-vs-update
+    operation: Update
+    overwritten: true
+    path: spec.template.spec.containers[nginx].resources.requests
+    time: "2044-06-17T19:56:27Z"
+  externalManager: kubectl-client-side-apply
+  kind: Deployment
+  name: nginx
+  namespace: default
+  originalManager: original-manager
+  overwritten: true
+`,
+		},
+		{
+			desc: "timeline of a multi document file with a list",
+			args: []string{"timeline", "-f", "testdata/before.yaml"},
+			expectedOutput: `
+OBJECT                      TIME                  MANAGER                    OPERATION  SUBRESOURCE  APIVERSION  FIELDS
+default/deployment/nginx    2024-06-17T19:56:27Z  kubectl-client-side-apply  Update     <none>       apps/v1     4
+default/deployment/nginx    2024-06-18T19:56:27Z  original-manager           Update     <none>       apps/v1     2
+default/configmap/settings  2024-06-17T19:56:27Z  helm                       Update     <none>       v1          2
+`,
+		},
+		{
+			desc: "lint",
+			args: []string{"lint", "--policy", "../../pkg/sarif/testdata/policy.yaml", "-f", "testdata/after.yaml"},
+			expectedOutput: `
+LOCATION                RULE                      LEVEL    MESSAGE
+testdata/after.yaml:53  optimizer-owns-resources  error    original-manager is not allowed to own spec.template.spec.containers[nginx].resources.limits
+testdata/after.yaml:38  replicas-by-autoscaler    warning  kubectl-client-side-apply is not allowed to own spec.replicas
+testdata/after.yaml:51  optimizer-owns-resources  error    kubectl-client-side-apply is not allowed to own spec.template.spec.containers[nginx].resources.requests
+`,
+		},
+		{
+			desc: "scan",
+			args: []string{"scan", "--manager", "stormforge-optimizer", "-f", "../../pkg/scan/testdata/backup/deployments.yaml"},
+			expectedOutput: `
+1 files, 2 objects, 2 objects with conflicts, 3 conflicts
+
+MANAGER       CATEGORY    OBJECTS  CONFLICTS  OVERWRITES
+kubectl-edit  human       2        2          1
+vpa-updater   autoscaler  1        1          1
+
+KIND             PATH                                                     OBJECTS  CONFLICTS  MANAGERS
+Deployment.apps  spec.template.spec.containers[*].resources.requests.cpu  2        2          kubectl-edit
+Deployment.apps  spec.template.spec.containers[*].resources.limits.cpu    1        1          vpa-updater
+`,
+		},
+		{
+			desc: "diff",
+			args: []string{"diff", "testdata/before.yaml", "testdata/after.yaml"},
+			expectedOutput: `
+OBJECT                    MANAGER                    CHANGE  DETAIL
+default/deployment/nginx  kubectl-client-side-apply  gained  spec.template.spec.containers[nginx].resources.requests
+default/deployment/nginx  original-manager           lost    spec.template.spec.containers[nginx].resources.requests
+default/deployment/nginx  kubectl-client-side-apply  time    2024-06-17T19:56:27Z -> 2024-06-19T08:00:00Z
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cmd := newRootCommand(genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}})
+			cmd.SetArgs(tc.args)
+
+			assert.NoError(t, cmd.Execute())
+			assert.Equal(t, strings.TrimPrefix(tc.expectedOutput, "\n"), out.String())
+		})
+	}
+}
+
+func TestCommandsWithoutInput(t *testing.T) {
+	cmd := newRootCommand(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.SetArgs([]string{"paths"})
+
+	assert.EqualError(t, cmd.Execute(), "either -f or resources (e.g. deployment/nginx) are required")
+}
+
+func TestInvalidIgnoreFile(t *testing.T) {
+	cmd := newRootCommand(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.SetArgs([]string{"conflicts", "--manager", "original-manager", "--ignore", "testdata/conflicts.json", "-f", "testdata/conflicts.json"})
+
+	assert.ErrorContains(t, cmd.Execute(), "testdata/conflicts.json: parsing ignore rules")
+}
+
+func TestConflictsWarnings(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := newRootCommand(genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: errOut})
+	cmd.SetArgs([]string{"conflicts", "--manager", "original-manager", "-f", "testdata/unsupported.yaml"})
+
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, "OBJECT  EXTERNAL MANAGER  OPERATION  TIME  OVERWRITTEN  PATH\n", out.String())
+	assert.Equal(t, `Warning: default/deployment/nginx: managedFields[1] of kubectl-client-side-apply: unsupported fieldsType "FieldsV2"`+"\n", errOut.String())
+}
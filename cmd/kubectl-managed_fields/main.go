@@ -0,0 +1,16 @@
+// kubectl-managed_fields is a kubectl plugin, invoked as kubectl managed-fields,
+// to inspect the managed fields of objects read from files, stdin or a live cluster.
+package main
+
+import (
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func main() {
+	streams := genericiooptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	if err := newRootCommand(streams).Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"managedfields/pkg/utils"
+)
+
+// options are the flags shared by all the subcommands
+type options struct {
+	configFlags *genericclioptions.ConfigFlags
+	filenames   []string
+	recursive   bool
+	ignoreFile  string
+
+	genericiooptions.IOStreams
+}
+
+func newOptions(streams genericiooptions.IOStreams) *options {
+	return &options{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+func (o *options) addFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringSliceVarP(&o.filenames, "filename", "f", nil, "Files or directories with the objects, YAML or JSON, - for stdin")
+	flags.BoolVarP(&o.recursive, "recursive", "R", false, "Process the directories of -f recursively")
+	flags.StringVar(&o.ignoreFile, "ignore", "", "File with the ignore rules of the benign writes of external managers, YAML or JSON")
+	o.configFlags.AddFlags(flags)
+}
+
+// detectOptions returns the options of the detection functions set by the flags
+func (o *options) detectOptions() ([]utils.DetectOption, error) {
+	if o.ignoreFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(o.ignoreFile)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := utils.ParseIgnoreList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", o.ignoreFile, err)
+	}
+
+	return []utils.DetectOption{utils.WithIgnoreList(ignore)}, nil
+}
+
+// objects returns the objects of the files given with -f, read without cluster access,
+// or of the resources given as arguments, read from the cluster of the kubeconfig
+func (o *options) objects(args []string) ([]*unstructured.Unstructured, error) {
+	if len(o.filenames) == 0 && len(args) == 0 {
+		return nil, fmt.Errorf("either -f or resources (e.g. deployment/nginx) are required")
+	}
+
+	builder := resource.NewBuilder(o.configFlags).
+		Unstructured().
+		ContinueOnError().
+		Flatten()
+
+	if len(o.filenames) > 0 {
+		builder = builder.FilenameParam(false, &resource.FilenameOptions{
+			Filenames: o.filenames,
+			Recursive: o.recursive,
+		})
+	}
+
+	if len(args) == 0 {
+		builder = builder.Local()
+	} else {
+		namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, err
+		}
+		builder = builder.
+			NamespaceParam(namespace).
+			DefaultNamespace().
+			ResourceTypeOrNameArgs(true, args...).
+			Latest()
+	}
+
+	infos, err := builder.Do().Infos()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		object, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object type %T", info.Object)
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// Helper function returning a tab writer aligning the columns of the tables
+func newTableWriter(o *options) *tabwriter.Writer {
+	return tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+}
+
+// Helper function returning the kind/namespace/name header of an object
+func objectName(object *unstructured.Unstructured) string {
+	name := strings.ToLower(object.GetKind()) + "/" + object.GetName()
+	if object.GetNamespace() != "" {
+		name = object.GetNamespace() + "/" + name
+	}
+	return name
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
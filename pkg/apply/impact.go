@@ -0,0 +1,164 @@
+package apply
+
+import (
+	"fmt"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"managedfields/pkg/utils"
+)
+
+// ForceImpact is the blast radius of a forced apply: the fields every other manager
+// loses to the applier and the ones it keeps, shared with the applier
+type ForceImpact struct {
+	Manager string
+	// Conflicts are the conflicts the apply forces
+	Conflicts []utils.FieldConflict
+	// Managers are the other managers owning fields of the applier, sorted by manager
+	Managers []ManagerImpact
+}
+
+// ManagerImpact is the impact of a forced apply on a manager
+type ManagerImpact struct {
+	Manager string
+	// Stripped are the fields the manager will lose
+	Stripped []utils.FieldPath
+	// Shared are the fields the manager will keep, owned by the applier as well
+	Shared []utils.FieldPath
+}
+
+// PreviewForce simulates the forced apply of the configuration by the manager
+// and returns which managers lose which fields and which keep shared ownership
+func (s *Simulator) PreviewForce(liveObject, applyConfig runtime.Object, manager string) (*ForceImpact, error) {
+
+	before := []metav1.ManagedFieldsEntry{}
+	if liveObject != nil {
+		live, err := toUnstructured(liveObject)
+		if err != nil {
+			return nil, err
+		}
+		before = live.GetManagedFields()
+	}
+
+	result, err := s.Simulate(liveObject, applyConfig, manager, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewForceImpact(manager, before, result)
+}
+
+// NewForceImpact computes the impact of a forced apply by the manager
+// from the managed fields before the apply and the result of the simulation
+func NewForceImpact(manager string, before []metav1.ManagedFieldsEntry, result *Result) (*ForceImpact, error) {
+
+	diff, err := utils.DiffManagedFields(before, result.ManagedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	applierFields := utils.NewFieldPathSet()
+	for _, managedField := range result.ManagedFields {
+		if managedField.Manager != manager || managedField.FieldsV1 == nil {
+			continue
+		}
+		fields, err := utils.FieldsV1ToFieldPathSet(managedField.FieldsV1)
+		if err != nil {
+			return nil, err
+		}
+		applierFields = applierFields.Union(fields)
+	}
+
+	impact := &ForceImpact{
+		Manager:   manager,
+		Conflicts: result.Conflicts,
+		Managers:  []ManagerImpact{},
+	}
+
+	for _, managerDiff := range diff.Managers {
+		if managerDiff.Manager == manager {
+			continue
+		}
+
+		kept := utils.NewFieldPathSet(managerDiff.Unchanged...).Union(utils.NewFieldPathSet(managerDiff.Gained...))
+		managerImpact := ManagerImpact{
+			Manager:  managerDiff.Manager,
+			Stripped: managerDiff.Lost,
+			Shared:   kept.Intersection(applierFields).List(),
+		}
+
+		if len(managerImpact.Stripped) == 0 && len(managerImpact.Shared) == 0 {
+			continue
+		}
+		impact.Managers = append(impact.Managers, managerImpact)
+	}
+
+	return impact, nil
+}
+
+// Stripped returns the number of fields stripped from the other managers
+func (i *ForceImpact) Stripped() int {
+	stripped := 0
+	for _, managerImpact := range i.Managers {
+		stripped += len(managerImpact.Stripped)
+	}
+	return stripped
+}
+
+// WriteSummary writes the impact for humans, e.g. for change approvals
+func (i *ForceImpact) WriteSummary(w io.Writer) error {
+
+	losers := 0
+	for _, managerImpact := range i.Managers {
+		if len(managerImpact.Stripped) > 0 {
+			losers++
+		}
+	}
+
+	if losers == 0 {
+		if _, err := fmt.Fprintf(w, "Forcing the apply of %s strips no fields\n", i.Manager); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintf(w, "Forcing the apply of %s strips %s from %s\n",
+			i.Manager, plural(i.Stripped(), "field"), plural(losers, "manager")); err != nil {
+			return err
+		}
+	}
+
+	for _, managerImpact := range i.Managers {
+		if len(managerImpact.Stripped) > 0 {
+			if err := writePaths(w, fmt.Sprintf("%s loses:", managerImpact.Manager), managerImpact.Stripped); err != nil {
+				return err
+			}
+		}
+		if len(managerImpact.Shared) > 0 {
+			if err := writePaths(w, fmt.Sprintf("%s keeps, shared with %s:", managerImpact.Manager, i.Manager), managerImpact.Shared); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writePaths(w io.Writer, title string, paths []utils.FieldPath) error {
+	if _, err := fmt.Fprintln(w, title); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := fmt.Fprintf(w, "  %s\n", path.Human()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
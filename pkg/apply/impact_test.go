@@ -0,0 +1,65 @@
+package apply
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewForce(t *testing.T) {
+	simulator := newSimulator(t)
+
+	created, err := simulator.Simulate(nil, mustUnstructured(t, gitopsConfig), "gitops", false)
+	require.NoError(t, err)
+
+	config := mustUnstructured(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+`)
+
+	impact, err := simulator.PreviewForce(created.Object, config, "stormforge-optimizer")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, impact.Stripped())
+	require.Len(t, impact.Conflicts, 1)
+	require.Len(t, impact.Managers, 1)
+	assert.Equal(t, "gitops", impact.Managers[0].Manager)
+	assert.Equal(t, []string{"spec.template.spec.containers[nginx].resources.requests.cpu"}, humanPaths(impact.Managers[0].Stripped))
+	assert.Equal(t, []string{
+		"spec.template.spec.containers[nginx]",
+		"spec.template.spec.containers[nginx].name",
+	}, humanPaths(impact.Managers[0].Shared))
+
+	var buf bytes.Buffer
+	require.NoError(t, impact.WriteSummary(&buf))
+	assert.Equal(t, `Forcing the apply of stormforge-optimizer strips 1 field from 1 manager
+gitops loses:
+  spec.template.spec.containers[nginx].resources.requests.cpu
+gitops keeps, shared with stormforge-optimizer:
+  spec.template.spec.containers[nginx]
+  spec.template.spec.containers[nginx].name
+`, buf.String())
+
+	// nothing to strip when the values are the same
+	impact, err = simulator.PreviewForce(created.Object, mustUnstructured(t, gitopsConfig), "stormforge-optimizer")
+	require.NoError(t, err)
+
+	buf.Reset()
+	require.NoError(t, impact.WriteSummary(&buf))
+	assert.Contains(t, buf.String(), "Forcing the apply of stormforge-optimizer strips no fields\n")
+	assert.Equal(t, 0, impact.Stripped())
+}
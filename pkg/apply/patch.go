@@ -0,0 +1,363 @@
+package apply
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"managedfields/pkg/utils"
+)
+
+// metadata fields set by the apiserver, never part of a patch
+var serverMetadata = map[string]struct{}{
+	"creationTimestamp": {},
+	"deletionTimestamp": {},
+	"generation":        {},
+	"managedFields":     {},
+	"resourceVersion":   {},
+	"selfLink":          {},
+	"uid":               {},
+}
+
+// Patch is an apply configuration limited to the fields a controller may write
+type Patch struct {
+	// Object has the desired values of the fields of the manager and of the new fields,
+	// to send as an apply patch with the manager as field manager. The fields of the manager
+	// missing from the desired object are left out, so the apply removes them.
+	Object *unstructured.Unstructured
+	// Dropped are the desired changes of fields owned by other managers only
+	Dropped []utils.FieldPath
+}
+
+// Data returns the JSON of the patch, for types.ApplyPatchType
+func (p *Patch) Data() ([]byte, error) {
+	return json.Marshal(p.Object)
+}
+
+// OwnedPatch compares the desired object of a controller with the live object
+// and returns a patch with the desired values of the fields the manager owns
+// and of the fields not in the live object, dropping the changes to fields
+// owned by other managers only
+func OwnedPatch(liveObject, desiredObject runtime.Object, manager string) (*Patch, error) {
+
+	live, err := toUnstructured(liveObject)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := toUnstructured(desiredObject)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newPatchBuilder(live.GetManagedFields(), manager)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &Patch{
+		Object:  &unstructured.Unstructured{Object: map[string]interface{}{}},
+		Dropped: []utils.FieldPath{},
+	}
+	patch.Object.SetAPIVersion(desired.GetAPIVersion())
+	patch.Object.SetKind(desired.GetKind())
+	patch.Object.SetName(desired.GetName())
+	if namespace := desired.GetNamespace(); namespace != "" {
+		patch.Object.SetNamespace(namespace)
+	}
+
+	for _, key := range sortedKeys(desired.Object) {
+		if key == "apiVersion" || key == "kind" {
+			continue
+		}
+
+		desiredValue := desired.Object[key]
+		liveValue, liveFound := live.Object[key]
+
+		if key == "metadata" {
+			desiredValue = withoutServerMetadata(desiredValue)
+		}
+
+		path := utils.FieldPath{{Kind: utils.FieldElement, Value: key}}
+		if value, include := b.walk(path, desiredValue, liveValue, liveFound); include {
+			if key == "metadata" {
+				for name, v := range value.(map[string]interface{}) {
+					patch.Object.Object["metadata"].(map[string]interface{})[name] = v
+				}
+				continue
+			}
+			patch.Object.Object[key] = value
+		}
+	}
+
+	patch.Dropped = b.dropped
+
+	return patch, nil
+}
+
+type patchBuilder struct {
+	manager utils.FieldPathSet
+	others  utils.FieldPathSet
+	// keys of the associative lists, by list path
+	listKeys map[string][]string
+	// paths of the lists of sets
+	sets    map[string]struct{}
+	dropped []utils.FieldPath
+}
+
+// Helper function collecting the fields of the manager, of the other managers
+// and the kinds of the lists, from every entry of the main resource
+func newPatchBuilder(managedFields []metav1.ManagedFieldsEntry, manager string) (*patchBuilder, error) {
+
+	b := &patchBuilder{
+		manager:  utils.NewFieldPathSet(),
+		others:   utils.NewFieldPathSet(),
+		listKeys: map[string][]string{},
+		sets:     map[string]struct{}{},
+	}
+
+	for _, managedField := range managedFields {
+		if managedField.FieldsV1 == nil || managedField.Subresource != "" {
+			continue
+		}
+
+		paths, err := utils.ParseFieldsV1(managedField.FieldsV1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			path = canonicalPath(path)
+
+			if managedField.Manager == manager {
+				b.manager.Insert(path)
+			} else {
+				b.others.Insert(path)
+			}
+
+			for idx, element := range path {
+				switch element.Kind {
+				case utils.KeyElement:
+					var key map[string]interface{}
+					if err := json.Unmarshal([]byte(element.Value), &key); err == nil {
+						b.listKeys[path[:idx].String()] = sortedKeys(key)
+					}
+				case utils.ValueElement:
+					b.sets[path[:idx].String()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// walk returns the value to patch at the path and whether to include it
+func (b *patchBuilder) walk(path utils.FieldPath, desired, live interface{}, liveFound bool) (interface{}, bool) {
+
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveMap, _ := live.(map[string]interface{})
+		result := map[string]interface{}{}
+		for _, key := range sortedKeys(desiredValue) {
+			liveValue, found := liveMap[key]
+			child := appendElement(path, utils.PathElement{Kind: utils.FieldElement, Value: key})
+			if value, include := b.walk(child, desiredValue[key], liveValue, liveFound && found); include {
+				result[key] = value
+			}
+		}
+		switch {
+		case len(result) > 0:
+			return result, true
+		case len(desiredValue) == 0:
+			return b.leaf(path, desired, live, liveFound)
+		case owns(b.manager, path):
+			// a map of the manager as a whole, or the map itself
+			if b.manager.Has(appendElement(path, utils.PathElement{Kind: utils.SelfElement})) {
+				return result, true
+			}
+			return desired, true
+		}
+		return nil, false
+
+	case []interface{}:
+		if keys, found := b.listKeys[path.String()]; found {
+			return b.walkAssociativeList(path, keys, desiredValue, live, liveFound)
+		}
+		if _, found := b.sets[path.String()]; found {
+			return b.walkSet(path, desiredValue, live, liveFound)
+		}
+	}
+
+	return b.leaf(path, desired, live, liveFound)
+}
+
+func (b *patchBuilder) walkAssociativeList(path utils.FieldPath, keys []string, desired []interface{}, live interface{}, liveFound bool) (interface{}, bool) {
+
+	liveItems := map[string]interface{}{}
+	if liveList, ok := live.([]interface{}); ok {
+		for _, item := range liveList {
+			if element, ok := keyElement(keys, item); ok {
+				liveItems[element.Value] = item
+			}
+		}
+	}
+
+	result := []interface{}{}
+	for _, item := range desired {
+		element, ok := keyElement(keys, item)
+		if !ok {
+			// without its keys the item is new to everybody
+			result = append(result, item)
+			continue
+		}
+
+		liveItem, found := liveItems[element.Value]
+		value, include := b.walk(appendElement(path, element), item, liveItem, liveFound && found)
+		if !include {
+			continue
+		}
+
+		// the keys identify the item in the apply configuration
+		if itemMap, ok := value.(map[string]interface{}); ok {
+			for _, key := range keys {
+				itemMap[key] = item.(map[string]interface{})[key]
+			}
+		}
+		result = append(result, value)
+	}
+
+	return result, len(result) > 0
+}
+
+func (b *patchBuilder) walkSet(path utils.FieldPath, desired []interface{}, live interface{}, liveFound bool) (interface{}, bool) {
+
+	liveValues := map[string]struct{}{}
+	if liveList, ok := live.([]interface{}); ok {
+		for _, value := range liveList {
+			liveValues[marshal(value)] = struct{}{}
+		}
+	}
+
+	result := []interface{}{}
+	for _, value := range desired {
+		element := utils.PathElement{Kind: utils.ValueElement, Value: marshal(value)}
+		_, found := liveValues[element.Value]
+		if _, include := b.leaf(appendElement(path, element), value, value, liveFound && found); include {
+			result = append(result, value)
+		}
+	}
+
+	return result, len(result) > 0
+}
+
+// leaf decides for a value the manager writes as a whole
+func (b *patchBuilder) leaf(path utils.FieldPath, desired, live interface{}, liveFound bool) (interface{}, bool) {
+
+	if owns(b.manager, path) {
+		return desired, true
+	}
+
+	// newly desired fields
+	if !liveFound {
+		return desired, true
+	}
+
+	if reflect.DeepEqual(desired, live) {
+		return nil, false
+	}
+
+	if owns(b.others, path) {
+		b.dropped = append(b.dropped, path)
+		return nil, false
+	}
+
+	// changes of fields nobody owns
+	return desired, true
+}
+
+// owns returns whether the set has the path, the path itself ("."), or an ancestor owned as a whole
+func owns(set utils.FieldPathSet, path utils.FieldPath) bool {
+	if set.Has(path) || set.Has(appendElement(path, utils.PathElement{Kind: utils.SelfElement})) {
+		return true
+	}
+	for ancestor := path.Parent(); len(ancestor) > 0; ancestor = ancestor.Parent() {
+		if set.Has(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function returning the "k:" element of a list item
+func keyElement(keys []string, item interface{}) (utils.PathElement, bool) {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok {
+		return utils.PathElement{}, false
+	}
+
+	key := map[string]interface{}{}
+	for _, name := range keys {
+		value, found := itemMap[name]
+		if !found {
+			return utils.PathElement{}, false
+		}
+		key[name] = value
+	}
+
+	return utils.PathElement{Kind: utils.KeyElement, Value: marshal(key)}, true
+}
+
+// Helper function re-encoding the keys and values of the paths,
+// so they compare with the ones built from the objects
+func canonicalPath(path utils.FieldPath) utils.FieldPath {
+	canonical := make(utils.FieldPath, 0, len(path))
+	for _, element := range path {
+		if element.Kind == utils.KeyElement || element.Kind == utils.ValueElement {
+			var value interface{}
+			if err := json.Unmarshal([]byte(element.Value), &value); err == nil {
+				element.Value = marshal(value)
+			}
+		}
+		canonical = append(canonical, element)
+	}
+	return canonical
+}
+
+func appendElement(path utils.FieldPath, element utils.PathElement) utils.FieldPath {
+	return append(path[:len(path):len(path)], element)
+}
+
+func marshal(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func withoutServerMetadata(metadata interface{}) interface{} {
+	metadataMap, ok := metadata.(map[string]interface{})
+	if !ok {
+		return metadata
+	}
+	result := map[string]interface{}{}
+	for key, value := range metadataMap {
+		if _, found := serverMetadata[key]; !found {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,94 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestOwnedPatch(t *testing.T) {
+	simulator := newSimulator(t)
+
+	created, err := simulator.Simulate(nil, mustUnstructured(t, gitopsConfig), "gitops", false)
+	require.NoError(t, err)
+
+	optimized, err := simulator.Simulate(created.Object, mustUnstructured(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+`), "stormforge-optimizer", true)
+	require.NoError(t, err)
+
+	desired := mustUnstructured(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  labels:
+    app: nginx
+  annotations:
+    stormforge.io/last-updated: "2024-06-17T19:56:27Z"
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.28
+        resources:
+          limits:
+            cpu: "1"
+          requests:
+            cpu: 500m
+            memory: 64Mi
+`)
+
+	patch, err := OwnedPatch(optimized.Object, desired, "stormforge-optimizer")
+	require.NoError(t, err)
+
+	data, err := yaml.Marshal(patch.Object.Object)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    stormforge.io/last-updated: "2024-06-17T19:56:27Z"
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          limits:
+            cpu: "1"
+          requests:
+            cpu: 500m
+            memory: 64Mi
+`, string(data))
+	assert.Equal(t, []string{
+		"spec.replicas",
+		"spec.template.spec.containers[nginx].image",
+	}, humanPaths(patch.Dropped))
+
+	// the patch applies without conflicts
+	result, err := simulator.Simulate(optimized.Object, patch.Object, "stormforge-optimizer", false)
+	require.NoError(t, err)
+	assert.True(t, result.Applied)
+	assert.Empty(t, result.Conflicts)
+}
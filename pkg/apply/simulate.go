@@ -0,0 +1,236 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+
+	"managedfields/pkg/utils"
+)
+
+// fields the apiserver never tracks in the managed fields
+var stripSet = fieldpath.NewSet(
+	fieldpath.MakePathOrDie("apiVersion"),
+	fieldpath.MakePathOrDie("kind"),
+	fieldpath.MakePathOrDie("metadata"),
+	fieldpath.MakePathOrDie("metadata", "name"),
+	fieldpath.MakePathOrDie("metadata", "namespace"),
+	fieldpath.MakePathOrDie("metadata", "creationTimestamp"),
+	fieldpath.MakePathOrDie("metadata", "selfLink"),
+	fieldpath.MakePathOrDie("metadata", "uid"),
+	fieldpath.MakePathOrDie("metadata", "clusterName"),
+	fieldpath.MakePathOrDie("metadata", "generation"),
+	fieldpath.MakePathOrDie("metadata", "managedFields"),
+	fieldpath.MakePathOrDie("metadata", "resourceVersion"),
+)
+
+// Result is the outcome of a simulated server-side apply
+type Result struct {
+	// Applied is false when the apply would be rejected because of conflicts
+	Applied bool
+	// Object is the object after the apply, with its managed fields,
+	// the live object when the apply is rejected
+	Object *unstructured.Unstructured
+	// ManagedFields are the managed fields after the apply
+	ManagedFields []metav1.ManagedFieldsEntry
+	// Conflicts are the fields of other managers the apply changes,
+	// the original manager of each conflict is the owner of the field
+	// and the external manager is the applier
+	Conflicts []utils.FieldConflict
+}
+
+// Simulator simulates server-side applies offline, with the type converter of the kinds,
+// e.g. openapi.LoadTypeConverter or managedfields.NewDeducedTypeConverter for kinds without schema
+type Simulator struct {
+	typeConverter managedfields.TypeConverter
+	updater       *merge.Updater
+	// Now returns the time of the applies, metav1.Now by default
+	Now func() metav1.Time
+}
+
+// NewSimulator returns a Simulator with the type converter
+func NewSimulator(typeConverter managedfields.TypeConverter) *Simulator {
+	builder := merge.UpdaterBuilder{Converter: versionConverter{}}
+	return &Simulator{
+		typeConverter: typeConverter,
+		updater:       builder.BuildUpdater(),
+		Now:           metav1.Now,
+	}
+}
+
+// Simulate computes the managed fields and the conflicts of applying the configuration
+// to the live object with the manager, as the apiserver would. The live object is nil
+// when the apply creates the object. Conflicts are returned whether or not force is set,
+// without force they reject the apply.
+func (s *Simulator) Simulate(liveObject, applyConfig runtime.Object, manager string, force bool) (*Result, error) {
+
+	config, err := toUnstructured(applyConfig)
+	if err != nil {
+		return nil, err
+	}
+	if config.GetManagedFields() != nil {
+		return nil, fmt.Errorf("metadata.managedFields must be nil")
+	}
+
+	live := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	live.SetGroupVersionKind(config.GroupVersionKind())
+	if liveObject != nil {
+		live, err = toUnstructured(liveObject)
+		if err != nil {
+			return nil, err
+		}
+		if live.GroupVersionKind() != config.GroupVersionKind() {
+			return nil, fmt.Errorf("apply configuration of %s does not match the live object %s", config.GroupVersionKind(), live.GroupVersionKind())
+		}
+	}
+
+	managed, times, err := utils.ManagedFieldsToSMD(live.GetManagedFields())
+	if err != nil {
+		return nil, err
+	}
+
+	// the managed fields are not part of the merged objects
+	liveWithoutManagedFields := live.DeepCopy()
+	liveWithoutManagedFields.SetManagedFields(nil)
+
+	typedLive, err := s.typeConverter.ObjectToTyped(liveWithoutManagedFields, typed.AllowDuplicates)
+	if err != nil {
+		return nil, fmt.Errorf("live object: %w", err)
+	}
+	typedConfig, err := s.typeConverter.ObjectToTyped(config)
+	if err != nil {
+		return nil, fmt.Errorf("apply configuration: %w", err)
+	}
+
+	identifier, err := utils.ManagerIdentifier(metav1.ManagedFieldsEntry{
+		Manager:   manager,
+		Operation: metav1.ManagedFieldsOperationApply,
+	})
+	if err != nil {
+		return nil, err
+	}
+	version := fieldpath.APIVersion(config.GetAPIVersion())
+	now := s.Now()
+
+	result := &Result{
+		Conflicts: []utils.FieldConflict{},
+	}
+
+	// always apply without force first to know the conflicts
+	typedMerged, merged, err := s.updater.Apply(typedLive, typedConfig, version, managed.Copy(), identifier, false)
+
+	var conflicts merge.Conflicts
+	if errors.As(err, &conflicts) {
+		result.Conflicts, err = toFieldConflicts(conflicts, manager, string(version), &now, force)
+		if err != nil {
+			return nil, err
+		}
+		if !force {
+			result.Object = live
+			result.ManagedFields = live.GetManagedFields()
+			return result, nil
+		}
+		typedMerged, merged, err = s.updater.Apply(typedLive, typedConfig, version, managed.Copy(), identifier, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the applier gets the time of the apply when the object changes,
+	// the other managers keep theirs
+	if typedMerged != nil {
+		times[identifier] = &now
+	} else {
+		typedMerged = typedLive
+	}
+
+	for id, versionedSet := range merged {
+		merged[id] = fieldpath.NewVersionedSet(versionedSet.Set().Difference(stripSet), versionedSet.APIVersion(), versionedSet.Applied())
+		if merged[id].Set().Empty() {
+			delete(merged, id)
+		}
+	}
+
+	result.ManagedFields, err = utils.ManagedFieldsFromSMD(merged, times)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := s.typeConverter.TypedToObject(typedMerged)
+	if err != nil {
+		return nil, err
+	}
+	result.Object, err = toUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	result.Object.SetManagedFields(result.ManagedFields)
+	result.Applied = true
+
+	return result, nil
+}
+
+// Helper function converting the conflicts of structured-merge-diff,
+// sorted by manager and path
+func toFieldConflicts(conflicts merge.Conflicts, manager, apiVersion string, now *metav1.Time, force bool) ([]utils.FieldConflict, error) {
+
+	fieldConflicts := make([]utils.FieldConflict, 0, len(conflicts))
+
+	for _, conflict := range conflicts {
+		path, err := utils.FieldPathFromSMD(conflict.Path)
+		if err != nil {
+			return nil, err
+		}
+		owner := utils.ParseManagerIdentifier(conflict.Manager)
+		fieldConflicts = append(fieldConflicts, utils.FieldConflict{
+			OriginalManager: owner.Manager,
+			ExternalManager: manager,
+			Category:        utils.ClassifyManager(manager),
+			Kind:            utils.NewConflictKind(owner.Operation, metav1.ManagedFieldsOperationApply),
+			Path:            path,
+			Operation:       metav1.ManagedFieldsOperationApply,
+			APIVersion:      apiVersion,
+			Time:            now,
+			Overwritten:     force,
+		})
+	}
+
+	sort.SliceStable(fieldConflicts, func(i, j int) bool {
+		if fieldConflicts[i].OriginalManager != fieldConflicts[j].OriginalManager {
+			return fieldConflicts[i].OriginalManager < fieldConflicts[j].OriginalManager
+		}
+		return fieldConflicts[i].Path.String() < fieldConflicts[j].Path.String()
+	})
+
+	return fieldConflicts, nil
+}
+
+func toUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := object.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// versionConverter doesn't convert, the objects are compared in the version of the apply
+type versionConverter struct{}
+
+func (versionConverter) Convert(object *typed.TypedValue, _ fieldpath.APIVersion) (*typed.TypedValue, error) {
+	return object, nil
+}
+
+func (versionConverter) IsMissingVersionError(error) bool {
+	return false
+}
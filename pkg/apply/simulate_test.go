@@ -0,0 +1,257 @@
+package apply
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"managedfields/pkg/openapi"
+	"managedfields/pkg/utils"
+)
+
+const gitopsConfig = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  labels:
+    app: nginx
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.27
+        resources:
+          requests:
+            cpu: 100m
+`
+
+func mustUnstructured(t *testing.T, document string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(document), &object.Object))
+	return object
+}
+
+func newSimulator(t *testing.T) *Simulator {
+	typeConverter, err := openapi.LoadTypeConverter("../openapi/testdata/apps-v1.json")
+	require.NoError(t, err)
+
+	simulator := NewSimulator(typeConverter)
+	simulator.Now = func() metav1.Time {
+		return metav1.NewTime(utils.MustParseTime("2024-06-17T19:56:27Z"))
+	}
+	return simulator
+}
+
+// owners returns the manager and path of every owned field, e.g. "gitops spec.replicas"
+func owners(t *testing.T, managedFields []metav1.ManagedFieldsEntry) []string {
+	result := []string{}
+	for _, managedField := range managedFields {
+		paths, err := utils.ParseFieldsV1(managedField.FieldsV1)
+		require.NoError(t, err)
+		for _, path := range paths {
+			result = append(result, fmt.Sprintf("%s %s", managedField.Manager, path.Human()))
+		}
+	}
+	return result
+}
+
+func TestSimulate(t *testing.T) {
+	simulator := newSimulator(t)
+
+	created, err := simulator.Simulate(nil, mustUnstructured(t, gitopsConfig), "gitops", false)
+	require.NoError(t, err)
+	require.True(t, created.Applied)
+	assert.Equal(t, []string{
+		"gitops metadata.labels.app",
+		"gitops spec.replicas",
+		"gitops spec.template.spec.containers[nginx]",
+		"gitops spec.template.spec.containers[nginx].image",
+		"gitops spec.template.spec.containers[nginx].name",
+		"gitops spec.template.spec.containers[nginx].resources.requests.cpu",
+	}, owners(t, created.ManagedFields))
+
+	testCases := []struct {
+		desc              string
+		config            string
+		force             bool
+		expectedApplied   bool
+		expectedConflicts []string
+		expectedOwners    []string
+		expectedCPU       string
+	}{
+		{
+			desc: "same value is shared",
+			config: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          requests:
+            cpu: 100m
+`,
+			expectedApplied:   true,
+			expectedConflicts: []string{},
+			expectedOwners: []string{
+				"gitops metadata.labels.app",
+				"gitops spec.replicas",
+				"gitops spec.template.spec.containers[nginx]",
+				"gitops spec.template.spec.containers[nginx].image",
+				"gitops spec.template.spec.containers[nginx].name",
+				"gitops spec.template.spec.containers[nginx].resources.requests.cpu",
+				"stormforge-optimizer spec.template.spec.containers[nginx]",
+				"stormforge-optimizer spec.template.spec.containers[nginx].name",
+				"stormforge-optimizer spec.template.spec.containers[nginx].resources.requests.cpu",
+			},
+			expectedCPU: "100m",
+		},
+		{
+			desc: "different value conflicts",
+			config: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+`,
+			expectedApplied:   false,
+			expectedConflicts: []string{"gitops spec.template.spec.containers[nginx].resources.requests.cpu"},
+			expectedOwners: []string{
+				"gitops metadata.labels.app",
+				"gitops spec.replicas",
+				"gitops spec.template.spec.containers[nginx]",
+				"gitops spec.template.spec.containers[nginx].image",
+				"gitops spec.template.spec.containers[nginx].name",
+				"gitops spec.template.spec.containers[nginx].resources.requests.cpu",
+			},
+			expectedCPU: "100m",
+		},
+		{
+			desc: "forced different value takes the field",
+			config: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+`,
+			force:             true,
+			expectedApplied:   true,
+			expectedConflicts: []string{"gitops spec.template.spec.containers[nginx].resources.requests.cpu"},
+			expectedOwners: []string{
+				"gitops metadata.labels.app",
+				"gitops spec.replicas",
+				"gitops spec.template.spec.containers[nginx]",
+				"gitops spec.template.spec.containers[nginx].image",
+				"gitops spec.template.spec.containers[nginx].name",
+				"stormforge-optimizer spec.template.spec.containers[nginx]",
+				"stormforge-optimizer spec.template.spec.containers[nginx].name",
+				"stormforge-optimizer spec.template.spec.containers[nginx].resources.requests.cpu",
+				"stormforge-optimizer spec.template.spec.containers[nginx].resources.requests.memory",
+			},
+			expectedCPU: "250m",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			result, err := simulator.Simulate(created.Object, mustUnstructured(t, tc.config), "stormforge-optimizer", tc.force)
+			require.NoError(t, err)
+
+			conflicts := []string{}
+			for _, conflict := range result.Conflicts {
+				assert.Equal(t, "stormforge-optimizer", conflict.ExternalManager)
+				assert.Equal(t, tc.force, conflict.Overwritten)
+				assert.Equal(t, utils.ApplyVsApply, conflict.Kind)
+				conflicts = append(conflicts, fmt.Sprintf("%s %s", conflict.OriginalManager, conflict.Path.Human()))
+			}
+
+			assert.Equal(t, tc.expectedApplied, result.Applied)
+			assert.Equal(t, tc.expectedConflicts, conflicts)
+			assert.Equal(t, tc.expectedOwners, owners(t, result.ManagedFields))
+
+			containers, _, err := unstructured.NestedSlice(result.Object.Object, "spec", "template", "spec", "containers")
+			require.NoError(t, err)
+			require.Len(t, containers, 1)
+			cpu, _, err := unstructured.NestedString(containers[0].(map[string]interface{}), "resources", "requests", "cpu")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCPU, cpu)
+		})
+	}
+}
+
+func TestSimulateTimes(t *testing.T) {
+	simulator := newSimulator(t)
+
+	created, err := simulator.Simulate(nil, mustUnstructured(t, gitopsConfig), "gitops", false)
+	require.NoError(t, err)
+	require.Len(t, created.ManagedFields, 1)
+	assert.Equal(t, metav1.ManagedFieldsOperationApply, created.ManagedFields[0].Operation)
+	assert.Equal(t, "apps/v1", created.ManagedFields[0].APIVersion)
+
+	// applying the same configuration later is a no-op keeping the time
+	simulator.Now = func() metav1.Time {
+		return metav1.NewTime(utils.MustParseTime("2024-06-17T19:56:27Z").Add(time.Hour))
+	}
+	reapplied, err := simulator.Simulate(created.Object, mustUnstructured(t, gitopsConfig), "gitops", false)
+	require.NoError(t, err)
+	require.Len(t, reapplied.ManagedFields, 1)
+	assert.True(t, created.ManagedFields[0].Time.Equal(reapplied.ManagedFields[0].Time))
+}
+
+func TestSimulateErrors(t *testing.T) {
+	simulator := newSimulator(t)
+
+	live := mustUnstructured(t, gitopsConfig)
+	config := mustUnstructured(t, gitopsConfig)
+	config.SetAPIVersion("apps/v1beta2")
+
+	_, err := simulator.Simulate(live, config, "gitops", false)
+	assert.EqualError(t, err, "apply configuration of apps/v1beta2, Kind=Deployment does not match the live object apps/v1, Kind=Deployment")
+
+	config = mustUnstructured(t, gitopsConfig)
+	config.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "gitops"}})
+	_, err = simulator.Simulate(live, config, "gitops", false)
+	assert.EqualError(t, err, "metadata.managedFields must be nil")
+}
+
+func humanPaths(paths []utils.FieldPath) []string {
+	result := []string{}
+	for _, path := range paths {
+		result = append(result, path.Human())
+	}
+	return result
+}
@@ -0,0 +1,396 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/report"
+	"managedfields/pkg/utils"
+)
+
+// StageResponseComplete is the stage of the events with the response object
+const StageResponseComplete = "ResponseComplete"
+
+// Event is the subset of an audit.k8s.io/v1 Event needed to follow field ownership
+type Event struct {
+	Level                    string            `json:"level"`
+	AuditID                  string            `json:"auditID"`
+	Stage                    string            `json:"stage"`
+	RequestURI               string            `json:"requestURI"`
+	Verb                     string            `json:"verb"`
+	User                     UserInfo          `json:"user"`
+	ImpersonatedUser         *UserInfo         `json:"impersonatedUser,omitempty"`
+	SourceIPs                []string          `json:"sourceIPs,omitempty"`
+	UserAgent                string            `json:"userAgent,omitempty"`
+	ObjectRef                *ObjectReference  `json:"objectRef,omitempty"`
+	ResponseStatus           *metav1.Status    `json:"responseStatus,omitempty"`
+	ResponseObject           json.RawMessage   `json:"responseObject,omitempty"`
+	RequestReceivedTimestamp metav1.MicroTime  `json:"requestReceivedTimestamp"`
+	StageTimestamp           metav1.MicroTime  `json:"stageTimestamp"`
+	Annotations              map[string]string `json:"annotations,omitempty"`
+}
+
+// UserInfo is the authenticated user of a request
+type UserInfo struct {
+	Username string              `json:"username,omitempty"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+}
+
+// ObjectReference is the object of a request
+type ObjectReference struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	UID         string `json:"uid,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// ReadFile reads the events of an audit log file
+func ReadFile(filename string) ([]Event, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadEvents(file)
+}
+
+// ReadEvents reads the events of an audit log, one JSON event per line,
+// keeping the completed writes with their response objects only
+func ReadEvents(r io.Reader) ([]Event, error) {
+
+	events := []Event{}
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if isWrite(event) {
+				events = append(events, event)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// Helper function keeping the successful writes logged with their response objects
+func isWrite(event Event) bool {
+	if event.Stage != StageResponseComplete || len(event.ResponseObject) == 0 || event.ObjectRef == nil {
+		return false
+	}
+	if event.ResponseStatus != nil && event.ResponseStatus.Code >= 300 {
+		return false
+	}
+	switch event.Verb {
+	case "create", "update", "patch":
+		return true
+	}
+	return false
+}
+
+// History is the field ownership history of the objects of audit events
+type History struct {
+	// Objects are sorted by API version, kind, namespace and name
+	Objects []*ObjectHistory
+}
+
+// ObjectHistory is the field ownership history of one object
+type ObjectHistory struct {
+	APIVersion string
+	Kind       string
+	Resource   string
+	Namespace  string
+	Name       string
+	// Changes are sorted by time
+	Changes []Change
+	// ManagedFields are the managed fields after the last change
+	ManagedFields []metav1.ManagedFieldsEntry
+}
+
+// Change is a write of an object, with the fields that changed hands
+type Change struct {
+	AuditID     string
+	Time        metav1.Time
+	Verb        string
+	Subresource string
+	User        UserInfo
+	// ImpersonatedUser is the user the request was made as, when User impersonated it
+	ImpersonatedUser *UserInfo
+	SourceIPs        []string
+	UserAgent        string
+	// Writers are the entries the request wrote: new entries or entries with a new time
+	Writers []metav1.ManagedFieldsEntry
+	// Managers are the managers whose fields changed, sorted by manager
+	Managers []utils.ManagerFieldsDiff
+	// ManagedFields are the managed fields after the change
+	ManagedFields []metav1.ManagedFieldsEntry
+}
+
+// NewHistory reconstructs the field ownership history of the objects of the events,
+// comparing the managed fields of the response of every write with the previous one.
+// The first write of an object in the events is compared with no managed fields.
+func NewHistory(events []Event) (*History, error) {
+
+	sorted := append([]Event{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].RequestReceivedTimestamp.Before(&sorted[j].RequestReceivedTimestamp)
+	})
+
+	history := &History{Objects: []*ObjectHistory{}}
+	objects := map[string]*ObjectHistory{}
+
+	for _, event := range sorted {
+		if !isWrite(event) {
+			continue
+		}
+
+		var object metav1.PartialObjectMetadata
+		if err := json.Unmarshal(event.ResponseObject, &object); err != nil {
+			return nil, fmt.Errorf("audit event %s: %w", event.AuditID, err)
+		}
+
+		key := fmt.Sprintf("%s/%s/%s/%s", event.ObjectRef.APIGroup, event.ObjectRef.Resource, object.Namespace, object.Name)
+		objectHistory, found := objects[key]
+		if !found {
+			objectHistory = &ObjectHistory{
+				APIVersion:    object.APIVersion,
+				Kind:          object.Kind,
+				Resource:      event.ObjectRef.Resource,
+				Namespace:     object.Namespace,
+				Name:          object.Name,
+				Changes:       []Change{},
+				ManagedFields: []metav1.ManagedFieldsEntry{},
+			}
+			objects[key] = objectHistory
+			history.Objects = append(history.Objects, objectHistory)
+		}
+
+		diff, err := utils.DiffManagedFields(objectHistory.ManagedFields, object.ManagedFields)
+		if err != nil {
+			return nil, fmt.Errorf("audit event %s: %w", event.AuditID, err)
+		}
+
+		written, err := writers(diff, object.ManagedFields)
+		if err != nil {
+			return nil, fmt.Errorf("audit event %s: %w", event.AuditID, err)
+		}
+
+		change := Change{
+			AuditID:          event.AuditID,
+			Time:             metav1.NewTime(event.RequestReceivedTimestamp.Time),
+			Verb:             event.Verb,
+			Subresource:      event.ObjectRef.Subresource,
+			User:             event.User,
+			ImpersonatedUser: event.ImpersonatedUser,
+			SourceIPs:        event.SourceIPs,
+			UserAgent:        event.UserAgent,
+			Writers:          written,
+			Managers:         []utils.ManagerFieldsDiff{},
+			ManagedFields:    object.ManagedFields,
+		}
+		for _, managerDiff := range diff.Managers {
+			if len(managerDiff.Gained) > 0 || len(managerDiff.Lost) > 0 {
+				change.Managers = append(change.Managers, managerDiff)
+			}
+		}
+
+		objectHistory.Changes = append(objectHistory.Changes, change)
+		objectHistory.ManagedFields = object.ManagedFields
+	}
+
+	sort.SliceStable(history.Objects, func(i, j int) bool {
+		a, b := history.Objects[i], history.Objects[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return history, nil
+}
+
+// Helper function returning the entries written by a change, the added entries
+// and the entries with a new time, identified by utils.ManagerIdentifier
+func writers(diff utils.ManagedFieldsDiff, after []metav1.ManagedFieldsEntry) ([]metav1.ManagedFieldsEntry, error) {
+
+	written := map[string]struct{}{}
+	for _, entry := range diff.Added {
+		identifier, err := utils.ManagerIdentifier(entry)
+		if err != nil {
+			return nil, err
+		}
+		written[identifier] = struct{}{}
+	}
+	for _, timeChange := range diff.TimeChanges {
+		identifier, err := utils.ManagerIdentifier(metav1.ManagedFieldsEntry{
+			Manager:     timeChange.Manager,
+			Operation:   timeChange.Operation,
+			Subresource: timeChange.Subresource,
+			APIVersion:  timeChange.APIVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		written[identifier] = struct{}{}
+	}
+
+	result := []metav1.ManagedFieldsEntry{}
+	for _, entry := range after {
+		identifier, err := utils.ManagerIdentifier(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := written[identifier]; found {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// Conflicts returns the fields the original manager lost, each one to the manager
+// that gained it in the same change, or to the writer when nobody gained it
+func (h *ObjectHistory) Conflicts(originalManager string) []utils.FieldConflict {
+
+	conflicts := []utils.FieldConflict{}
+	before := []metav1.ManagedFieldsEntry{}
+
+	for _, change := range h.Changes {
+		lost := utils.NewFieldPathSet()
+		for _, managerDiff := range change.Managers {
+			if managerDiff.Manager == originalManager {
+				lost.Insert(managerDiff.Lost...)
+			}
+		}
+
+		for _, path := range lost.List() {
+			writer := change.writerOf(path)
+			changeTime := change.Time
+			conflicts = append(conflicts, utils.FieldConflict{
+				OriginalManager: originalManager,
+				ExternalManager: writer.Manager,
+				Category:        utils.ClassifyManager(writer.Manager),
+				Kind:            utils.NewConflictKind(operationOf(before, originalManager, path), writer.Operation),
+				Path:            path,
+				Operation:       writer.Operation,
+				Subresource:     writer.Subresource,
+				APIVersion:      writer.APIVersion,
+				Time:            &changeTime,
+				Overwritten:     true,
+			})
+		}
+
+		before = change.ManagedFields
+	}
+
+	return conflicts
+}
+
+// Helper function returning the operation of the entry of the manager owning the path,
+// Update when none does
+func operationOf(managedFields []metav1.ManagedFieldsEntry, manager string, path utils.FieldPath) metav1.ManagedFieldsOperationType {
+	for _, managedField := range managedFields {
+		if managedField.Manager != manager || managedField.FieldsV1 == nil {
+			continue
+		}
+		fields, err := utils.FieldsV1ToFieldPathSet(managedField.FieldsV1)
+		if err != nil {
+			continue
+		}
+		if fields.Has(path) {
+			return managedField.Operation
+		}
+	}
+	return metav1.ManagedFieldsOperationUpdate
+}
+
+// writerOf returns the entry of the manager gaining the path in the change,
+// the first writer when nobody gained it
+func (c Change) writerOf(path utils.FieldPath) metav1.ManagedFieldsEntry {
+
+	for _, managerDiff := range c.Managers {
+		if !utils.NewFieldPathSet(managerDiff.Gained...).Has(path) {
+			continue
+		}
+		// the entry with the path, e.g. of the API version the manager wrote it with
+		writers := []metav1.ManagedFieldsEntry{}
+		for _, writer := range c.Writers {
+			if writer.Manager != managerDiff.Manager {
+				continue
+			}
+			if fields, err := utils.FieldsV1ToFieldPathSet(writer.FieldsV1); err == nil && fields.Has(path) {
+				return writer
+			}
+			writers = append(writers, writer)
+		}
+		if len(writers) > 0 {
+			return writers[0]
+		}
+		return metav1.ManagedFieldsEntry{Manager: managerDiff.Manager}
+	}
+
+	if len(c.Writers) > 0 {
+		return c.Writers[0]
+	}
+
+	// e.g. the field was removed by a client not tracked by the managed fields
+	return metav1.ManagedFieldsEntry{Manager: c.UserAgent}
+}
+
+// Report returns the conflict report of the fields the original manager lost in the history,
+// with the objects where it lost fields only
+func (h *History) Report(originalManager string) (*report.Report, error) {
+
+	result := report.New()
+	generatedAt := metav1.Now()
+	result.GeneratedAt = &generatedAt
+
+	for _, objectHistory := range h.Objects {
+		conflicts := objectHistory.Conflicts(originalManager)
+		if len(conflicts) == 0 {
+			continue
+		}
+
+		object := &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: objectHistory.APIVersion, Kind: objectHistory.Kind},
+			ObjectMeta: metav1.ObjectMeta{Namespace: objectHistory.Namespace, Name: objectHistory.Name},
+		}
+		reportObject, err := report.NewObject(object, originalManager, conflicts)
+		if err != nil {
+			return nil, err
+		}
+		result.Objects = append(result.Objects, reportObject)
+	}
+
+	h.Correlate(result)
+
+	return result, nil
+}
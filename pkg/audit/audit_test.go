@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+func humanPaths(paths []utils.FieldPath) []string {
+	result := []string{}
+	for _, path := range paths {
+		result = append(result, path.Human())
+	}
+	return result
+}
+
+func TestReadEvents(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		log              string
+		expectedAuditIDs []string
+		expectedErr      string
+	}{
+		{
+			desc: "writes with response objects only",
+			log: `{"auditID":"a1","stage":"ResponseComplete","verb":"patch","objectRef":{"resource":"deployments"},"responseStatus":{"code":200},"responseObject":{}}
+{"auditID":"a2","stage":"RequestReceived","verb":"patch","objectRef":{"resource":"deployments"}}
+
+{"auditID":"a3","stage":"ResponseComplete","verb":"get","objectRef":{"resource":"deployments"},"responseStatus":{"code":200},"responseObject":{}}
+{"auditID":"a4","stage":"ResponseComplete","verb":"update","objectRef":{"resource":"deployments"},"responseStatus":{"code":409},"responseObject":{}}
+{"auditID":"a5","stage":"ResponseComplete","verb":"create","objectRef":{"resource":"deployments"},"responseStatus":{"code":201}}
+{"auditID":"a6","stage":"ResponseComplete","verb":"create","objectRef":{"resource":"deployments"},"responseStatus":{"code":201},"responseObject":{}}`,
+			expectedAuditIDs: []string{"a1", "a6"},
+		},
+		{
+			desc:        "invalid line",
+			log:         "{\"auditID\":\"a1\"}\n{\"auditID\":",
+			expectedErr: "line 2: unexpected end of JSON input",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			events, err := ReadEvents(strings.NewReader(tc.log))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			auditIDs := []string{}
+			for _, event := range events {
+				auditIDs = append(auditIDs, event.AuditID)
+			}
+			assert.Equal(t, tc.expectedAuditIDs, auditIDs)
+		})
+	}
+}
+
+func TestNewHistory(t *testing.T) {
+	events, err := ReadFile("testdata/audit.log")
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	history, err := NewHistory(events)
+	require.NoError(t, err)
+	require.Len(t, history.Objects, 1)
+
+	object := history.Objects[0]
+	assert.Equal(t, "apps/v1", object.APIVersion)
+	assert.Equal(t, "Deployment", object.Kind)
+	assert.Equal(t, "default", object.Namespace)
+	assert.Equal(t, "nginx", object.Name)
+	require.Len(t, object.Changes, 3)
+
+	// the optimizer takes the requests from argocd
+	change := object.Changes[1]
+	assert.Equal(t, "a2", change.AuditID)
+	assert.Equal(t, "system:serviceaccount:stormforge:optimizer", change.User.Username)
+	assert.Equal(t, "stormforge-optimizer/1.4.0", change.UserAgent)
+	require.Len(t, change.Writers, 1)
+	assert.Equal(t, "stormforge-optimizer", change.Writers[0].Manager)
+	require.Len(t, change.Managers, 2)
+	assert.Equal(t, "argocd-controller", change.Managers[0].Manager)
+	assert.Equal(t, []string{"spec.template.spec.containers[nginx].resources.requests.cpu"}, humanPaths(change.Managers[0].Lost))
+	assert.Equal(t, "stormforge-optimizer", change.Managers[1].Manager)
+	assert.Equal(t, []string{
+		"spec.template.spec.containers[nginx]",
+		"spec.template.spec.containers[nginx].name",
+		"spec.template.spec.containers[nginx].resources.requests.cpu",
+	}, humanPaths(change.Managers[1].Gained))
+
+	conflicts := object.Conflicts("stormforge-optimizer")
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "kubectl-edit", conflicts[0].ExternalManager)
+	assert.Equal(t, "spec.template.spec.containers[nginx].resources.requests.cpu", conflicts[0].Path.Human())
+	assert.Equal(t, "Update", string(conflicts[0].Operation))
+	assert.Equal(t, utils.UpdateStoleApply, conflicts[0].Kind)
+	assert.Equal(t, "2024-06-17T21:00:00Z", conflicts[0].Time.UTC().Format("2006-01-02T15:04:05Z"))
+	assert.True(t, conflicts[0].Overwritten)
+
+	result, err := history.Report("argocd-controller")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	assert.Equal(t, "nginx", result.Objects[0].Name)
+	assert.Equal(t, "stormforge-optimizer", result.Objects[0].ExternalManager)
+	require.Len(t, result.Objects[0].Conflicts, 1)
+	assert.Equal(t, "spec.template.spec.containers[nginx].resources.requests.cpu", result.Objects[0].Conflicts[0].Path)
+
+	result, err = history.Report("kubectl-edit")
+	require.NoError(t, err)
+	assert.Empty(t, result.Objects)
+}
+
+func TestWritersAPIVersions(t *testing.T) {
+	v1 := metav1.ManagedFieldsEntry{
+		APIVersion:  "autoscaling/v1",
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:currentReplicas":{}}}`)},
+		Manager:     "kube-controller-manager",
+		Operation:   "Update",
+		Subresource: "status",
+		Time:        &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+	}
+	v2 := *v1.DeepCopy()
+	v2.APIVersion = "autoscaling/v2"
+	v2.FieldsV1 = &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:conditions":{}}}`)}
+	v2Again := *v2.DeepCopy()
+	v2Again.FieldsV1 = &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:conditions":{},"f:currentMetrics":{}}}`)}
+	v2Again.Time = &metav1.Time{Time: utils.MustParseTime("2024-06-18T19:56:27Z")}
+
+	before := []metav1.ManagedFieldsEntry{v1, v2}
+	after := []metav1.ManagedFieldsEntry{v1, v2Again}
+
+	diff, err := utils.DiffManagedFields(before, after)
+	require.NoError(t, err)
+
+	// the entry of the other API version was not written
+	written, err := writers(diff, after)
+	require.NoError(t, err)
+	assert.Equal(t, []metav1.ManagedFieldsEntry{v2Again}, written)
+
+	change := Change{Writers: written, Managers: diff.Managers}
+	writer := change.writerOf(utils.FieldPath{
+		{Kind: utils.FieldElement, Value: "status"},
+		{Kind: utils.FieldElement, Value: "currentMetrics"},
+	})
+	assert.Equal(t, "autoscaling/v2", writer.APIVersion)
+}
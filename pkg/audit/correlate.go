@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/report"
+	"managedfields/pkg/utils"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// Actor returns who made the change: the impersonated user when there is one,
+// with its service account, source IPs and user agent
+func (c Change) Actor() report.Actor {
+
+	user := c.User
+	impersonator := ""
+	if c.ImpersonatedUser != nil {
+		user = *c.ImpersonatedUser
+		impersonator = c.User.Username
+	}
+
+	actor := report.Actor{
+		Username:     user.Username,
+		UID:          user.UID,
+		Groups:       user.Groups,
+		Impersonator: impersonator,
+		SourceIPs:    c.SourceIPs,
+		UserAgent:    c.UserAgent,
+		AuditID:      c.AuditID,
+	}
+
+	// system:serviceaccount:<namespace>:<name>
+	if serviceAccount, found := strings.CutPrefix(user.Username, serviceAccountPrefix); found {
+		actor.ServiceAccount = strings.Replace(serviceAccount, ":", "/", 1)
+	}
+
+	return actor
+}
+
+// ActorOf returns who wrote the managed fields entry of the object, found by the utils.ManagerIdentifier
+// of the entry, any API version when it has none, and its time, or the time of the request in seconds
+// like the times of the managed fields. Entries without time match the latest write.
+func (h *History) ActorOf(apiVersion, kind, namespace, name string, managedField metav1.ManagedFieldsEntry) (*report.Actor, bool) {
+
+	for _, objectHistory := range h.Objects {
+		if objectHistory.APIVersion != apiVersion || objectHistory.Kind != kind ||
+			objectHistory.Namespace != namespace || objectHistory.Name != name {
+			continue
+		}
+
+		// the latest change first, the same entry can be written many times
+		for idx := len(objectHistory.Changes) - 1; idx >= 0; idx-- {
+			change := objectHistory.Changes[idx]
+			for _, writer := range change.Writers {
+				if !sameEntry(writer, managedField) {
+					continue
+				}
+				if managedField.Time == nil || managedField.Time.Equal(writer.Time) ||
+					managedField.Time.Truncate(time.Second).Equal(change.Time.Truncate(time.Second)) {
+					actor := change.Actor()
+					return &actor, true
+				}
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Helper function returning true if the writer is the entry, in any API version when the entry has none
+func sameEntry(writer, managedField metav1.ManagedFieldsEntry) bool {
+	if managedField.APIVersion == "" {
+		writer.APIVersion = ""
+	}
+	writerIdentifier, err := utils.ManagerIdentifier(writer)
+	if err != nil {
+		return false
+	}
+	identifier, err := utils.ManagerIdentifier(managedField)
+	return err == nil && writerIdentifier == identifier
+}
+
+// Correlate sets the actors of the conflicts of the report, from the history of their objects,
+// and returns the number of conflicts with an actor
+func (h *History) Correlate(r *report.Report) int {
+
+	correlated := 0
+
+	for i := range r.Objects {
+		object := &r.Objects[i]
+		for j := range object.Conflicts {
+			conflict := &object.Conflicts[j]
+			actor, found := h.ActorOf(object.APIVersion, object.Kind, object.Namespace, object.Name, metav1.ManagedFieldsEntry{
+				Manager:     conflict.ExternalManager,
+				Operation:   metav1.ManagedFieldsOperationType(conflict.Operation),
+				Subresource: conflict.Subresource,
+				APIVersion:  conflict.APIVersion,
+				Time:        conflict.Time,
+			})
+			if found {
+				conflict.Actor = actor
+				correlated++
+			}
+		}
+	}
+
+	return correlated
+}
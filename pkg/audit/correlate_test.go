@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/report"
+	"managedfields/pkg/utils"
+)
+
+func TestChangeActor(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		change   Change
+		expected report.Actor
+	}{
+		{
+			desc: "user",
+			change: Change{
+				AuditID:   "a5",
+				User:      UserInfo{Username: "alice@example.com", Groups: []string{"platform-admins"}},
+				SourceIPs: []string{"192.168.1.7"},
+				UserAgent: "kubectl/v1.31.1",
+			},
+			expected: report.Actor{
+				Username:  "alice@example.com",
+				Groups:    []string{"platform-admins"},
+				SourceIPs: []string{"192.168.1.7"},
+				UserAgent: "kubectl/v1.31.1",
+				AuditID:   "a5",
+			},
+		},
+		{
+			desc: "service account",
+			change: Change{
+				User: UserInfo{Username: "system:serviceaccount:stormforge:optimizer", UID: "8c1f"},
+			},
+			expected: report.Actor{
+				Username:       "system:serviceaccount:stormforge:optimizer",
+				UID:            "8c1f",
+				ServiceAccount: "stormforge/optimizer",
+			},
+		},
+		{
+			desc: "impersonated service account",
+			change: Change{
+				User:             UserInfo{Username: "alice@example.com"},
+				ImpersonatedUser: &UserInfo{Username: "system:serviceaccount:argocd:argocd-application-controller"},
+			},
+			expected: report.Actor{
+				Username:       "system:serviceaccount:argocd:argocd-application-controller",
+				ServiceAccount: "argocd/argocd-application-controller",
+				Impersonator:   "alice@example.com",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.change.Actor())
+		})
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	events, err := ReadFile("testdata/audit.log")
+	require.NoError(t, err)
+
+	history, err := NewHistory(events)
+	require.NoError(t, err)
+
+	// the report of the history has the actors
+	result, err := history.Report("argocd-controller")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.Len(t, result.Objects[0].Conflicts, 1)
+	actor := result.Objects[0].Conflicts[0].Actor
+	require.NotNil(t, actor)
+	assert.Equal(t, "stormforge/optimizer", actor.ServiceAccount)
+	assert.Equal(t, []string{"10.0.0.40"}, actor.SourceIPs)
+	assert.Equal(t, "a2", actor.AuditID)
+
+	// the report of the live object gets them by the time of the entries
+	live := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nginx", ManagedFields: history.Objects[0].ManagedFields},
+	}
+	liveReport := report.New()
+	object, err := report.NewObject(live, "argocd-controller", []utils.FieldConflict{})
+	require.NoError(t, err)
+	liveReport.Objects = append(liveReport.Objects, object)
+
+	object, err = report.NewObject(live, "stormforge-optimizer", []utils.FieldConflict{{
+		OriginalManager: "stormforge-optimizer",
+		ExternalManager: "kubectl-edit",
+		Path:            utils.FieldPath{{Kind: utils.FieldElement, Value: "spec"}},
+		Operation:       "Update",
+		Time:            history.Objects[0].ManagedFields[2].Time,
+	}, {
+		OriginalManager: "stormforge-optimizer",
+		ExternalManager: "helm",
+		Path:            utils.FieldPath{{Kind: utils.FieldElement, Value: "spec"}},
+		Operation:       "Update",
+	}})
+	require.NoError(t, err)
+	liveReport.Objects = append(liveReport.Objects, object)
+
+	assert.Equal(t, 1, history.Correlate(liveReport))
+	actor = liveReport.Objects[1].Conflicts[0].Actor
+	require.NotNil(t, actor)
+	assert.Equal(t, "alice@example.com", actor.Username)
+	assert.Equal(t, []string{"platform-admins", "system:authenticated"}, actor.Groups)
+	assert.Equal(t, []string{"192.168.1.7", "10.0.0.1"}, actor.SourceIPs)
+	assert.Equal(t, "kubectl/v1.31.1 (linux/amd64) kubernetes/948afe5", actor.UserAgent)
+	assert.Nil(t, liveReport.Objects[1].Conflicts[1].Actor)
+}
+
+func TestActorOf(t *testing.T) {
+	receivedAt, err := time.Parse(time.RFC3339Nano, "2024-06-17T19:00:00.123456Z")
+	require.NoError(t, err)
+
+	history := &History{Objects: []*ObjectHistory{{
+		APIVersion: "autoscaling/v2",
+		Kind:       "HorizontalPodAutoscaler",
+		Namespace:  "default",
+		Name:       "nginx",
+		Changes: []Change{{
+			AuditID: "a1",
+			Time:    metav1.NewTime(receivedAt),
+			User:    UserInfo{Username: "system:serviceaccount:kube-system:horizontal-pod-autoscaler"},
+			Writers: []metav1.ManagedFieldsEntry{{
+				Manager:     "kube-controller-manager",
+				Operation:   "Update",
+				Subresource: "status",
+				APIVersion:  "autoscaling/v2",
+			}},
+		}},
+	}}}
+
+	testCases := []struct {
+		desc       string
+		apiVersion string
+		time       string
+		found      bool
+	}{
+		{desc: "time of the request in seconds", apiVersion: "autoscaling/v2", time: "2024-06-17T19:00:00Z", found: true},
+		{desc: "any API version", time: "2024-06-17T19:00:00Z", found: true},
+		{desc: "another API version", apiVersion: "autoscaling/v1", time: "2024-06-17T19:00:00Z", found: false},
+		{desc: "another time", apiVersion: "autoscaling/v2", time: "2024-06-17T19:00:01Z", found: false},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			actor, found := history.ActorOf("autoscaling/v2", "HorizontalPodAutoscaler", "default", "nginx", metav1.ManagedFieldsEntry{
+				Manager:     "kube-controller-manager",
+				Operation:   "Update",
+				Subresource: "status",
+				APIVersion:  tc.apiVersion,
+				Time:        &metav1.Time{Time: utils.MustParseTime(tc.time)},
+			})
+			assert.Equal(t, tc.found, found)
+			if found {
+				assert.Equal(t, "a1", actor.AuditID)
+			}
+		})
+	}
+}
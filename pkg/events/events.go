@@ -0,0 +1,62 @@
+package events
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"managedfields/pkg/utils"
+)
+
+// ReasonFieldOwnershipConflict is the reason of the events posted
+// when an external manager overwrites a field of the protected manager
+const ReasonFieldOwnershipConflict = "FieldOwnershipConflict"
+
+// ConflictRecorder posts Warning events on objects whose fields,
+// set by the protected manager, were overwritten by an external manager
+type ConflictRecorder struct {
+	recorder         record.EventRecorder
+	protectedManager string
+	options          []utils.DetectOption
+}
+
+// NewConflictRecorder returns a ConflictRecorder posting events through
+// the given recorder, e.g. the one of a controller or record.NewFakeRecorder in tests.
+// The options configure the detection of the conflicts, e.g. WithIgnoreList.
+func NewConflictRecorder(recorder record.EventRecorder, protectedManager string, opts ...utils.DetectOption) *ConflictRecorder {
+	return &ConflictRecorder{
+		recorder:         recorder,
+		protectedManager: protectedManager,
+		options:          opts,
+	}
+}
+
+// RecordConflicts detects the fields of the protected manager overwritten on the object
+// and posts one Warning event per field, so they show up in kubectl describe.
+// It returns the conflicts an event was posted for, and the managed fields entries
+// that could not be analyzed as an error, see utils.EntryErrors, the events of the others are still posted.
+func (r *ConflictRecorder) RecordConflicts(object runtime.Object) ([]utils.FieldConflict, error) {
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := []utils.FieldConflict{}
+
+	conflicts, err := utils.DetectFieldConflictsWithErrors(r.protectedManager, accessor.GetManagedFields(), r.options...)
+	for _, conflict := range conflicts {
+		// fields written before the protected manager were not overwritten
+		if !conflict.Overwritten {
+			continue
+		}
+
+		r.recorder.Eventf(object, corev1.EventTypeWarning, ReasonFieldOwnershipConflict,
+			"%s overwrote %s set by %s", conflict.ExternalManager, conflict.Path.Human(), conflict.OriginalManager)
+
+		recorded = append(recorded, conflict)
+	}
+
+	return recorded, err
+}
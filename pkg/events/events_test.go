@@ -0,0 +1,175 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"managedfields/pkg/utils"
+)
+
+func TestRecordConflicts(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		managedFields  []metav1.ManagedFieldsEntry
+		expectedEvents []string
+	}{
+		{
+			desc:           "no managed fields",
+			managedFields:  []metav1.ManagedFieldsEntry{},
+			expectedEvents: []string{},
+		},
+		{
+			desc: "original-manager being overwritten by external manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+			},
+			expectedEvents: []string{
+				"Warning FieldOwnershipConflict kubectl-client-side-apply overwrote spec.template.spec.containers[nginx].resources.requests set by original-manager",
+			},
+		},
+		{
+			desc: "external manager writing before original-manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+			},
+			expectedEvents: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			fakeRecorder := record.NewFakeRecorder(10)
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:          "nginx",
+					Namespace:     "default",
+					ManagedFields: tc.managedFields,
+				},
+			}
+
+			recorded, err := NewConflictRecorder(fakeRecorder, "original-manager").RecordConflicts(deployment)
+			assert.NoError(t, err)
+			assert.Len(t, recorded, len(tc.expectedEvents))
+
+			close(fakeRecorder.Events)
+			events := []string{}
+			for event := range fakeRecorder.Events {
+				events = append(events, event)
+			}
+			assert.Equal(t, tc.expectedEvents, events)
+		})
+	}
+}
+
+func TestConflictRecorderIgnoreList(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(10)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+			},
+		},
+	}
+
+	ignore, err := utils.NewIgnoreList(utils.IgnoreRule{Managers: []string{"kubectl-client-side-apply"}})
+	require.NoError(t, err)
+
+	recorded, err := NewConflictRecorder(fakeRecorder, "original-manager", utils.WithIgnoreList(ignore)).RecordConflicts(deployment)
+	assert.NoError(t, err)
+	assert.Empty(t, recorded)
+	assert.Empty(t, fakeRecorder.Events)
+}
+
+func TestRecordConflictsEntryErrors(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(10)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV2",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+					Manager:    "helm",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-18T19:56:27Z")},
+				},
+			},
+		},
+	}
+
+	// the event of the analyzed entry is still posted
+	recorded, err := NewConflictRecorder(fakeRecorder, "original-manager").RecordConflicts(deployment)
+	assert.EqualError(t, err, `managedFields[2] of helm: unsupported fieldsType "FieldsV2"`)
+	assert.Len(t, recorded, 1)
+	assert.Len(t, fakeRecorder.Events, 1)
+}
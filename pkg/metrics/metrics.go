@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"managedfields/pkg/utils"
+)
+
+const namespace = "managedfields"
+
+var (
+	overwriteLabels = []string{"group", "version", "kind", "namespace", "original_manager", "external_manager", "field"}
+	contestedLabels = []string{"group", "version", "kind", "namespace", "original_manager", "field"}
+	errorLabels     = []string{"group", "version", "kind", "namespace", "original_manager"}
+)
+
+// ConflictCollector is a prometheus.Collector exposing the field ownership conflicts
+// of the observed objects:
+//   - managedfields_overwrites_total counts the fields of the original manager overwritten by an external manager
+//   - managedfields_contested_fields is the number of fields currently written by both managers
+//   - managedfields_entry_errors_total counts the managed fields entries that could not be analyzed
+//
+// The field label is the top-level field subtree of the conflict, e.g. spec or metadata.
+type ConflictCollector struct {
+	overwrites  *prometheus.CounterVec
+	contested   *prometheus.GaugeVec
+	entryErrors *prometheus.CounterVec
+	options     []utils.DetectOption
+
+	mu sync.Mutex
+	// last observation per original manager and object,
+	// used to count each overwrite once and to keep the gauge current
+	observations map[objectKey]observation
+	// contested fields per gauge labels, summed across the observed objects
+	contestedTotals map[labelsKey]int
+}
+
+type objectKey struct {
+	originalManager string
+	group           string
+	version         string
+	kind            string
+	namespace       string
+	name            string
+}
+
+type labelsKey [6]string
+
+type observation struct {
+	overwrites  map[string]struct{}
+	contested   map[labelsKey]int
+	entryErrors map[string]struct{}
+}
+
+// NewConflictCollector returns a ConflictCollector, it needs to be registered with a prometheus.Registerer.
+// The options configure the detection of the conflicts, e.g. WithIgnoreList.
+func NewConflictCollector(opts ...utils.DetectOption) *ConflictCollector {
+	return &ConflictCollector{
+		overwrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "overwrites_total",
+			Help:      "Number of fields of the original manager overwritten by an external manager.",
+		}, overwriteLabels),
+		contested: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "contested_fields",
+			Help:      "Number of fields of the original manager currently also written by an external manager.",
+		}, contestedLabels),
+		entryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "entry_errors_total",
+			Help:      "Number of managed fields entries that could not be analyzed, e.g. with an unsupported fields type.",
+		}, errorLabels),
+		options:         opts,
+		observations:    map[objectKey]observation{},
+		contestedTotals: map[labelsKey]int{},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *ConflictCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.overwrites.Describe(ch)
+	c.contested.Describe(ch)
+	c.entryErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *ConflictCollector) Collect(ch chan<- prometheus.Metric) {
+	c.overwrites.Collect(ch)
+	c.contested.Collect(ch)
+	c.entryErrors.Collect(ch)
+}
+
+// Observe detects the conflicts of the original manager on the object and updates the metrics.
+// Observing the same object again only counts the overwrites not seen before,
+// and replaces its contribution to the contested fields gauge.
+// The managed fields entries that could not be analyzed are counted, once per object like the overwrites,
+// the conflicts of the others are still observed.
+// The object kind must be set, as it is on objects read with the dynamic client.
+func (c *ConflictCollector) Observe(originalManager string, object runtime.Object) error {
+
+	key, err := keyOf(originalManager, object)
+	if err != nil {
+		return err
+	}
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+
+	current := observation{
+		overwrites:  map[string]struct{}{},
+		contested:   map[labelsKey]int{},
+		entryErrors: map[string]struct{}{},
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.observations[key]
+
+	conflicts, err := utils.DetectFieldConflictsWithErrors(originalManager, accessor.GetManagedFields(), c.options...)
+	for _, entryError := range utils.EntryErrors(err) {
+		// an entry error is identified by its index, manager and error
+		id := entryError.Error()
+		current.entryErrors[id] = struct{}{}
+
+		if _, seen := previous.entryErrors[id]; seen {
+			continue
+		}
+		c.entryErrors.WithLabelValues(key.group, key.version, key.kind, key.namespace, originalManager).Inc()
+	}
+
+	// a field written by several external managers is contested once
+	contestedPaths := map[labelsKey]map[string]struct{}{}
+
+	for _, conflict := range conflicts {
+		field := topLevelField(conflict.Path)
+		labels := labelsKey{key.group, key.version, key.kind, key.namespace, originalManager, field}
+		if contestedPaths[labels] == nil {
+			contestedPaths[labels] = map[string]struct{}{}
+		}
+		contestedPaths[labels][conflict.Path.String()] = struct{}{}
+
+		if !conflict.Overwritten {
+			continue
+		}
+
+		// an overwrite is identified by the external manager, the field and the time of the write
+		id := conflict.ExternalManager + " " + conflict.Path.String()
+		if conflict.Time != nil {
+			id += " " + conflict.Time.UTC().String()
+		}
+		current.overwrites[id] = struct{}{}
+
+		if _, seen := previous.overwrites[id]; seen {
+			continue
+		}
+		c.overwrites.WithLabelValues(key.group, key.version, key.kind, key.namespace,
+			originalManager, conflict.ExternalManager, field).Inc()
+	}
+
+	for labels, paths := range contestedPaths {
+		current.contested[labels] = len(paths)
+	}
+
+	c.replaceContested(previous.contested, current.contested)
+	c.observations[key] = current
+
+	return nil
+}
+
+// Forget removes the contribution of the object to the contested fields gauge,
+// it is meant to be called when the object is deleted
+func (c *ConflictCollector) Forget(originalManager string, object runtime.Object) error {
+
+	key, err := keyOf(originalManager, object)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replaceContested(c.observations[key].contested, nil)
+	delete(c.observations, key)
+
+	return nil
+}
+
+// Helper function to swap the previous contested fields of an object by the current ones,
+// series reaching zero are deleted. Must be called with the lock held.
+func (c *ConflictCollector) replaceContested(previous, current map[labelsKey]int) {
+	for labels, count := range previous {
+		c.contestedTotals[labels] -= count
+	}
+	for labels, count := range current {
+		c.contestedTotals[labels] += count
+	}
+
+	for labels := range mergeKeys(previous, current) {
+		if c.contestedTotals[labels] <= 0 {
+			delete(c.contestedTotals, labels)
+			c.contested.DeleteLabelValues(labels[:]...)
+			continue
+		}
+		c.contested.WithLabelValues(labels[:]...).Set(float64(c.contestedTotals[labels]))
+	}
+}
+
+func mergeKeys(a, b map[labelsKey]int) map[labelsKey]struct{} {
+	keys := map[labelsKey]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+func keyOf(originalManager string, object runtime.Object) (objectKey, error) {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return objectKey{}, err
+	}
+
+	gvk := object.GetObjectKind().GroupVersionKind()
+
+	return objectKey{
+		originalManager: originalManager,
+		group:           gvk.Group,
+		version:         gvk.Version,
+		kind:            gvk.Kind,
+		namespace:       accessor.GetNamespace(),
+		name:            accessor.GetName(),
+	}, nil
+}
+
+// Helper function returning the top-level field of the path, e.g. spec
+func topLevelField(path utils.FieldPath) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return path[0].Value
+}
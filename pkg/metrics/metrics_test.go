@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+func deployment(name string, managedFields []metav1.ManagedFieldsEntry) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          name,
+			Namespace:     "default",
+			ManagedFields: managedFields,
+		},
+	}
+}
+
+func overwrittenRequests() []metav1.ManagedFieldsEntry {
+	return []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "original-manager",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+		},
+	}
+}
+
+func TestConflictCollector(t *testing.T) {
+	collector := NewConflictCollector()
+
+	// observing the same object twice counts the overwrite once
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", overwrittenRequests())))
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", overwrittenRequests())))
+	assert.NoError(t, collector.Observe("original-manager", deployment("other", overwrittenRequests())))
+
+	expected := `
+# HELP managedfields_contested_fields Number of fields of the original manager currently also written by an external manager.
+# TYPE managedfields_contested_fields gauge
+managedfields_contested_fields{field="spec",group="apps",kind="Deployment",namespace="default",original_manager="original-manager",version="v1"} 2
+# HELP managedfields_overwrites_total Number of fields of the original manager overwritten by an external manager.
+# TYPE managedfields_overwrites_total counter
+managedfields_overwrites_total{external_manager="kubectl-client-side-apply",field="spec",group="apps",kind="Deployment",namespace="default",original_manager="original-manager",version="v1"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+
+	// the object no longer contested and the deleted one are removed from the gauge
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", overwrittenRequests()[1:])))
+	assert.NoError(t, collector.Forget("original-manager", deployment("other", nil)))
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "managedfields_contested_fields"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "managedfields_overwrites_total"))
+}
+
+func TestConflictCollectorManagersOnOnePath(t *testing.T) {
+	// kubectl-edit overwriting the requests as well
+	managedFields := append(overwrittenRequests(), metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+		Manager:    "kubectl-edit",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-18T19:56:27Z")},
+	})
+
+	collector := NewConflictCollector()
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", managedFields)))
+
+	// the field is contested once, and overwritten by both managers
+	expected := `
+# HELP managedfields_contested_fields Number of fields of the original manager currently also written by an external manager.
+# TYPE managedfields_contested_fields gauge
+managedfields_contested_fields{field="spec",group="apps",kind="Deployment",namespace="default",original_manager="original-manager",version="v1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "managedfields_contested_fields"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "managedfields_overwrites_total"))
+}
+
+func TestConflictCollectorIgnoreList(t *testing.T) {
+	ignore, err := utils.NewIgnoreList(utils.IgnoreRule{Managers: []string{"kubectl-client-side-apply"}})
+	require.NoError(t, err)
+
+	collector := NewConflictCollector(utils.WithIgnoreList(ignore))
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", overwrittenRequests())))
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "managedfields_contested_fields"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "managedfields_overwrites_total"))
+}
+
+func TestConflictCollectorEntryErrors(t *testing.T) {
+	managedFields := append(overwrittenRequests(), metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV2",
+		FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+		Manager:    "helm",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-18T19:56:27Z")},
+	})
+
+	// observing the same object twice counts the entry once, its conflicts are still observed
+	collector := NewConflictCollector()
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", managedFields)))
+	assert.NoError(t, collector.Observe("original-manager", deployment("nginx", managedFields)))
+
+	expected := `
+# HELP managedfields_entry_errors_total Number of managed fields entries that could not be analyzed, e.g. with an unsupported fields type.
+# TYPE managedfields_entry_errors_total counter
+managedfields_entry_errors_total{group="apps",kind="Deployment",namespace="default",original_manager="original-manager",version="v1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "managedfields_entry_errors_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "managedfields_overwrites_total"))
+}
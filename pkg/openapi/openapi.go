@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Schema is the subset of an OpenAPI v3 schema, with the Kubernetes extensions,
+// needed to validate managed fields
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *SchemaOrBool      `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+
+	XListType              string                    `json:"x-kubernetes-list-type,omitempty"`
+	XListMapKeys           []string                  `json:"x-kubernetes-list-map-keys,omitempty"`
+	XMapType               string                    `json:"x-kubernetes-map-type,omitempty"`
+	XPreserveUnknownFields *bool                     `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	XEmbeddedResource      bool                      `json:"x-kubernetes-embedded-resource,omitempty"`
+	XIntOrString           bool                      `json:"x-kubernetes-int-or-string,omitempty"`
+	XGroupVersionKind      []schema.GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// SchemaOrBool is the value of additionalProperties, a schema or a boolean
+type SchemaOrBool struct {
+	Allows bool
+	Schema *Schema
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *SchemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Allows); err == nil {
+		return nil
+	}
+	s.Allows = true
+	return json.Unmarshal(data, &s.Schema)
+}
+
+// MarshalJSON implements json.Marshaler
+func (s SchemaOrBool) MarshalJSON() ([]byte, error) {
+	if s.Schema != nil {
+		return json.Marshal(s.Schema)
+	}
+	return json.Marshal(s.Allows)
+}
+
+// Document is a set of schemas, read from an OpenAPI v3 document or from CRDs,
+// with the root schemas of the kinds
+type Document struct {
+	schemas map[string]*Schema
+	kinds   map[schema.GroupVersionKind]*Schema
+}
+
+const componentsPrefix = "#/components/schemas/"
+
+// LoadOpenAPIV3 reads an OpenAPI v3 document, e.g. a dumped /openapi/v3/apis/apps/v1
+func LoadOpenAPIV3(filename string) (*Document, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOpenAPIV3(data)
+}
+
+// ParseOpenAPIV3 parses an OpenAPI v3 document, JSON or YAML
+func ParseOpenAPIV3(data []byte) (*Document, error) {
+	var document struct {
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI v3 document: %w", err)
+	}
+
+	d := &Document{
+		schemas: document.Components.Schemas,
+		kinds:   map[schema.GroupVersionKind]*Schema{},
+	}
+	if d.schemas == nil {
+		d.schemas = map[string]*Schema{}
+	}
+
+	for _, s := range d.schemas {
+		for _, gvk := range s.XGroupVersionKind {
+			d.kinds[gvk] = s
+		}
+	}
+
+	return d, nil
+}
+
+// LoadCRD reads a CustomResourceDefinition, YAML or JSON
+func LoadCRD(filename string) (*Document, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCRD(data)
+}
+
+// ParseCRD parses a CustomResourceDefinition, the schemas of its versions are the roots of its kind
+func ParseCRD(data []byte) (*Document, error) {
+	var crd struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Kind string `json:"kind"`
+			} `json:"names"`
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		return nil, fmt.Errorf("parsing CustomResourceDefinition: %w", err)
+	}
+
+	if crd.Kind != "CustomResourceDefinition" {
+		return nil, fmt.Errorf("expected a CustomResourceDefinition, got %q", crd.Kind)
+	}
+
+	d := &Document{
+		schemas: map[string]*Schema{},
+		kinds:   map[schema.GroupVersionKind]*Schema{},
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+		d.kinds[gvk] = version.Schema.OpenAPIV3Schema
+	}
+
+	return d, nil
+}
+
+// SchemaFor returns the root schema of the kind
+func (d *Document) SchemaFor(gvk schema.GroupVersionKind) (*Schema, error) {
+	s, found := d.kinds[gvk]
+	if !found {
+		return nil, fmt.Errorf("no schema for %s", gvk)
+	}
+	return s, nil
+}
+
+// resolve follows the references of the schema, including the single allOf
+// the Kubernetes documents use to reference schemas with defaults
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for depth := 0; s != nil; depth++ {
+		if depth > 100 {
+			return nil, fmt.Errorf("too many references")
+		}
+
+		switch {
+		case s.Ref != "":
+			name := strings.TrimPrefix(s.Ref, componentsPrefix)
+			resolved, found := d.schemas[name]
+			if !found {
+				return nil, fmt.Errorf("unresolved reference %q", s.Ref)
+			}
+			s = resolved
+		case len(s.AllOf) == 1 && s.Type == "" && len(s.Properties) == 0:
+			// the extensions of the wrapper apply to the referenced schema
+			if s.XMapType != "" || s.XListType != "" {
+				wrapped, err := d.resolve(s.AllOf[0])
+				if err != nil {
+					return nil, err
+				}
+				copied := *wrapped
+				if s.XMapType != "" {
+					copied.XMapType = s.XMapType
+				}
+				if s.XListType != "" {
+					copied.XListType = s.XListType
+				}
+				return &copied, nil
+			}
+			s = s.AllOf[0]
+		default:
+			return s, nil
+		}
+	}
+	return s, nil
+}
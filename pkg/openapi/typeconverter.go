@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadTypeConverter reads an OpenAPI v3 document into the type converter
+// structured-merge-diff uses to merge the objects of its kinds
+func LoadTypeConverter(filename string) (managedfields.TypeConverter, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTypeConverter(data)
+}
+
+// ParseTypeConverter parses an OpenAPI v3 document, JSON or YAML, into a type converter
+func ParseTypeConverter(data []byte) (managedfields.TypeConverter, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI v3 document: %w", err)
+	}
+
+	document := &spec3.OpenAPI{}
+	if err := document.UnmarshalJSON(jsonData); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI v3 document: %w", err)
+	}
+	if document.Components == nil || len(document.Components.Schemas) == 0 {
+		return nil, fmt.Errorf("OpenAPI v3 document without schemas")
+	}
+
+	return managedfields.NewTypeConverter(document.Components.Schemas, false)
+}
@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"managedfields/pkg/utils"
+)
+
+// ValidateManagedFields validates the FieldsV1 of every entry against the schema of the kind,
+// in the API version of the entry
+func (d *Document) ValidateManagedFields(kind string, managedFields []metav1.ManagedFieldsEntry) error {
+	errs := []error{}
+
+	for idx, managedField := range managedFields {
+		if managedField.FieldsV1 == nil {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(managedField.APIVersion)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err))
+			continue
+		}
+
+		if err := d.ValidateFieldsV1(gv.WithKind(kind), managedField.FieldsV1); err != nil {
+			errs = append(errs, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateFieldsV1 validates that every path of the FieldsV1 exists in the schema of the kind,
+// that the keys of the list items are the x-kubernetes-list-map-keys of their list,
+// that set items are in sets only and that atomic lists and maps have no owned children
+func (d *Document) ValidateFieldsV1(gvk schema.GroupVersionKind, fieldsV1 *metav1.FieldsV1) error {
+
+	root, err := d.SchemaFor(gvk)
+	if err != nil {
+		return err
+	}
+
+	if fieldsV1 == nil {
+		return fmt.Errorf("fieldsV1 nil")
+	}
+
+	var fieldsMap map[string]interface{}
+	if err := json.Unmarshal(fieldsV1.Raw, &fieldsMap); err != nil {
+		return err
+	}
+
+	errs := []error{}
+	d.validate(root, fieldsMap, utils.FieldPath{}, &errs)
+
+	return errors.Join(errs...)
+}
+
+func (d *Document) validate(s *Schema, fields map[string]interface{}, path utils.FieldPath, errs *[]error) {
+
+	s, err := d.resolve(s)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %w", humanPath(path), err))
+		return
+	}
+
+	if s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		element, err := utils.ParsePathElement(key)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", humanPath(path), err))
+			continue
+		}
+
+		if element.Kind == utils.SelfElement {
+			continue
+		}
+
+		childPath := append(path[:len(path):len(path)], element)
+
+		children, ok := fields[key].(map[string]interface{})
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: must be an object", humanPath(childPath)))
+			continue
+		}
+
+		childSchema, err := d.child(s, element, path)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", humanPath(childPath), err))
+			continue
+		}
+
+		if childSchema == nil || len(children) == 0 {
+			continue
+		}
+
+		d.validate(childSchema, children, childPath, errs)
+	}
+}
+
+// child returns the schema of the element under the schema, nil when anything is allowed under it
+func (d *Document) child(s *Schema, element utils.PathElement, path utils.FieldPath) (*Schema, error) {
+
+	switch {
+	case s.Type == "array":
+		return d.listItem(s, element)
+
+	case s.Type == "object" || len(s.Properties) > 0 || s.AdditionalProperties != nil:
+		if s.XMapType == "atomic" {
+			return nil, fmt.Errorf("is in an atomic map, only the map can be owned")
+		}
+		if element.Kind != utils.FieldElement {
+			return nil, fmt.Errorf("%q keys are not allowed in objects", string(element.Kind)+":")
+		}
+		if property, found := s.Properties[element.Value]; found {
+			return d.allowAnyMetadata(property, element, path)
+		}
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Allows {
+			return s.AdditionalProperties.Schema, nil
+		}
+		return nil, fmt.Errorf("unknown field")
+	}
+
+	return nil, fmt.Errorf("is in a scalar, which has no fields")
+}
+
+// allowAnyMetadata returns nil for the metadata of CRDs, which schemas don't describe it
+func (d *Document) allowAnyMetadata(property *Schema, element utils.PathElement, path utils.FieldPath) (*Schema, error) {
+	if len(path) > 0 || element.Value != "metadata" {
+		return property, nil
+	}
+
+	resolved, err := d.resolve(property)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved.Properties) == 0 {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+func (d *Document) listItem(s *Schema, element utils.PathElement) (*Schema, error) {
+
+	listType := s.XListType
+	if listType == "" {
+		listType = "atomic"
+	}
+
+	switch listType {
+	case "map":
+		if element.Kind != utils.KeyElement {
+			return nil, fmt.Errorf("items of the associative list must be \"k:\" keys")
+		}
+
+		var key map[string]interface{}
+		if err := json.Unmarshal([]byte(element.Value), &key); err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+
+		names := make([]string, 0, len(key))
+		for name := range key {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		expected := append([]string{}, s.XListMapKeys...)
+		sort.Strings(expected)
+
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			return nil, fmt.Errorf("key fields %v are not the x-kubernetes-list-map-keys %v", names, expected)
+		}
+
+		return s.Items, nil
+
+	case "set":
+		if element.Kind != utils.ValueElement {
+			return nil, fmt.Errorf("items of the set must be \"v:\" values")
+		}
+		// set items are scalars
+		return &Schema{Type: "string"}, nil
+	}
+
+	return nil, fmt.Errorf("is in an atomic list, only the list can be owned")
+}
+
+func humanPath(path utils.FieldPath) string {
+	if human := path.Human(); human != "" {
+		return human
+	}
+	return "<root>"
+}
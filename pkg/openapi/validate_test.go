@@ -0,0 +1,163 @@
+package openapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"managedfields/pkg/utils"
+)
+
+var (
+	deploymentV1   = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	optimizationV1 = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Optimization"}
+)
+
+func fieldsV1(raw string) *metav1.FieldsV1 {
+	return &metav1.FieldsV1{Raw: []byte(raw)}
+}
+
+func TestValidateFieldsV1(t *testing.T) {
+	document, err := LoadOpenAPIV3("testdata/apps-v1.json")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc        string
+		gvk         schema.GroupVersionKind
+		fieldsV1    *metav1.FieldsV1
+		expectedErr string
+	}{
+		{
+			desc:     "valid fields with containers",
+			gvk:      deploymentV1,
+			fieldsV1: utils.AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			desc:     "valid fields without containers",
+			gvk:      deploymentV1,
+			fieldsV1: utils.AppsV1ManagedFieldsMetaAndSpecWithoutContainers(),
+		},
+		{
+			desc:     "valid set and associative list with two keys",
+			gvk:      deploymentV1,
+			fieldsV1: fieldsV1(`{"f:metadata":{"f:finalizers":{".":{},"v:\"example.com/cleanup\"":{}}},"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{}}}}}}}}}`),
+		},
+		{
+			desc:        "unknown field",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:replica":{},"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{"f:resource":{}}}}}}}`),
+			expectedErr: "spec.replica: unknown field\nspec.template.spec.containers[nginx].resource: unknown field",
+		},
+		{
+			desc:        "key not matching the list map keys",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"image\":\"nginx\"}":{}}}}}}`),
+			expectedErr: "spec.template.spec.containers[nginx]: key fields [image] are not the x-kubernetes-list-map-keys [name]",
+		},
+		{
+			desc:        "item of an atomic list",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{"f:args":{"i:0":{}}}}}}}}`),
+			expectedErr: "spec.template.spec.containers[nginx].args[0]: is in an atomic list, only the list can be owned",
+		},
+		{
+			desc:        "field of an atomic map",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:selector":{"f:matchLabels":{}}}}`),
+			expectedErr: "spec.selector.matchLabels: is in an atomic map, only the map can be owned",
+		},
+		{
+			desc:        "key in a set",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:metadata":{"f:finalizers":{"k:{\"name\":\"cleanup\"}":{}}}}`),
+			expectedErr: `metadata.finalizers[cleanup]: items of the set must be "v:" values`,
+		},
+		{
+			desc:        "field of a scalar",
+			gvk:         deploymentV1,
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:replicas":{"f:value":{}}}}`),
+			expectedErr: "spec.replicas.value: is in a scalar, which has no fields",
+		},
+		{
+			desc:        "unknown kind",
+			gvk:         schema.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+			fieldsV1:    utils.HPAV2ManagedFieldsSpecMetricsAndMaxReplicas(),
+			expectedErr: "no schema for autoscaling/v2, Kind=HorizontalPodAutoscaler",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			err := document.ValidateFieldsV1(tc.gvk, tc.fieldsV1)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestValidateFieldsV1CRD(t *testing.T) {
+	document, err := LoadCRD("testdata/crd.yaml")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc        string
+		fieldsV1    *metav1.FieldsV1
+		expectedErr string
+	}{
+		{
+			desc:     "metadata and preserved unknown fields",
+			fieldsV1: fieldsV1(`{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:parameters":{"f:anything":{"f:nested":{}}},"f:targets":{"k:{\"name\":\"nginx\"}":{".":{},"f:cpu":{}}}}}`),
+		},
+		{
+			desc:        "children of int-or-string",
+			fieldsV1:    fieldsV1(`{"f:spec":{"f:targets":{"k:{\"name\":\"nginx\"}":{"f:cpu":{"f:value":{}}}}}}`),
+			expectedErr: "spec.targets[nginx].cpu.value: is in a scalar, which has no fields",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			err := document.ValidateFieldsV1(optimizationV1, tc.fieldsV1)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestValidateManagedFields(t *testing.T) {
+	document, err := LoadOpenAPIV3("testdata/apps-v1.json")
+	require.NoError(t, err)
+
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			APIVersion: "apps/v1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			Manager:    "hand-written",
+			Operation:  "Update",
+			APIVersion: "apps/v1",
+			FieldsV1:   fieldsV1(`{"f:spec":{"f:replica":{}}}`),
+		},
+		{
+			Manager:    "old-client",
+			Operation:  "Update",
+			APIVersion: "extensions/v1beta1",
+			FieldsV1:   fieldsV1(`{"f:spec":{"f:replicas":{}}}`),
+		},
+	}
+
+	err = document.ValidateManagedFields("Deployment", managedFields)
+	assert.EqualError(t, err, `managedFields[1] of hand-written: spec.replica: unknown field
+managedFields[2] of old-client: no schema for extensions/v1beta1, Kind=Deployment`)
+}
@@ -0,0 +1,298 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"managedfields/pkg/utils"
+)
+
+const (
+	// APIVersion is the version of the policy documents
+	APIVersion = "managedfields/v1alpha1"
+	// Kind is the kind of the policy documents
+	Kind = "OwnershipPolicy"
+)
+
+// Action is what a rule does when a manager that is not allowed owns a protected path
+type Action string
+
+const (
+	// Allow exempts the paths of the rule, no violation is reported
+	Allow Action = "allow"
+	// Warn reports a violation to be surfaced as a warning
+	Warn Action = "warn"
+	// Deny reports a violation to be enforced, e.g. by rejecting the request
+	Deny Action = "deny"
+	// Report reports a violation for auditing only
+	Report Action = "report"
+)
+
+// Policy is a declarative document listing who may own what, e.g.
+//
+//	apiVersion: managedfields/v1alpha1
+//	kind: OwnershipPolicy
+//	rules:
+//	- name: optimizer-owns-resources
+//	  paths:
+//	  - spec.template.spec.containers[*].resources
+//	  managers:
+//	  - stormforge-optimizer
+//	  action: deny
+type Policy struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Rules      []Rule `json:"rules"`
+}
+
+// Rule protects the fields under its paths, only its managers may own them.
+// The first rule with a path matching a field decides for it.
+type Rule struct {
+	Name string `json:"name,omitempty"`
+	// Paths are dotted path globs, "*" matches a field, "[*]" any list item
+	// and "**" any number of levels, e.g. spec.template.spec.containers[*].resources
+	Paths []string `json:"paths"`
+	// Managers are the manager names allowed to own the fields, as path.Match patterns
+	// matched as written and normalized, see utils.NormalizeManager, e.g. helm allows helm-v3.15.2
+	Managers []string `json:"managers,omitempty"`
+	// Categories are the manager categories allowed to own the fields, e.g. system,
+	// or, prefixed with "!", the only ones not allowed, e.g. "!human" allows every manager but humans
+	Categories []string `json:"categories,omitempty"`
+	Action     Action   `json:"action"`
+}
+
+// Violation is a field owned by a manager not allowed by the rule matching it
+type Violation struct {
+	// Rule is the name of the rule, or rules[index] when unnamed
+	Rule    string
+	Action  Action
+	Manager string
+	// Category is the category of the manager
+	Category utils.ManagerCategory
+	Path     utils.FieldPath
+	// Index of the managed fields entry owning the field
+	Index     int
+	Operation metav1.ManagedFieldsOperationType
+	Time      *metav1.Time
+}
+
+// Parse parses and validates a YAML or JSON policy document
+func Parse(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// ParseFile reads and parses a policy document
+func ParseFile(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Validate returns all the problems of the policy
+func (p *Policy) Validate() error {
+	errs := []error{}
+
+	if p.APIVersion != APIVersion {
+		errs = append(errs, fmt.Errorf("apiVersion must be %q, got %q", APIVersion, p.APIVersion))
+	}
+	if p.Kind != Kind {
+		errs = append(errs, fmt.Errorf("kind must be %q, got %q", Kind, p.Kind))
+	}
+	if len(p.Rules) == 0 {
+		errs = append(errs, errors.New("rules must not be empty"))
+	}
+
+	for idx, rule := range p.Rules {
+		if _, err := rule.compile(); err != nil {
+			errs = append(errs, fmt.Errorf("rules[%d]: %w", idx, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// compiledRule is a rule with its path globs parsed
+type compiledRule struct {
+	Rule
+	// id is the name of the rule, or its index when unnamed
+	id       string
+	patterns []utils.PathPattern
+}
+
+func (r Rule) compile() (compiledRule, error) {
+	compiled := compiledRule{Rule: r}
+	errs := []error{}
+
+	switch r.Action {
+	case Allow, Warn, Deny, Report:
+	default:
+		errs = append(errs, fmt.Errorf("action must be one of allow, warn, deny or report, got %q", r.Action))
+	}
+
+	if len(r.Paths) == 0 {
+		errs = append(errs, errors.New("paths must not be empty"))
+	}
+
+	for _, p := range r.Paths {
+		pattern, err := utils.ParsePathPattern(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		compiled.patterns = append(compiled.patterns, pattern)
+	}
+
+	for _, manager := range r.Managers {
+		if _, err := path.Match(manager, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid manager pattern %q: %w", manager, err))
+		}
+	}
+
+	excluded := 0
+	for _, category := range r.Categories {
+		if _, err := utils.ParseManagerCategory(strings.TrimPrefix(category, "!")); err != nil {
+			errs = append(errs, err)
+		}
+		if strings.HasPrefix(category, "!") {
+			excluded++
+		}
+	}
+	if excluded > 0 && excluded < len(r.Categories) {
+		errs = append(errs, errors.New("categories must be all allowed or all excluded with \"!\""))
+	}
+
+	return compiled, errors.Join(errs...)
+}
+
+// matches returns true if any of the rule paths matches the field path
+func (r compiledRule) matches(fieldPath utils.FieldPath) bool {
+	for _, pattern := range r.patterns {
+		if pattern.Matches(fieldPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if the manager, as written or normalized, is one of the rule managers,
+// or of its categories
+func (r compiledRule) allows(manager string, category utils.ManagerCategory) bool {
+	normalized := utils.NormalizeManager(manager)
+	for _, pattern := range r.Managers {
+		if matched, _ := path.Match(pattern, manager); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, normalized); matched {
+			return true
+		}
+	}
+
+	if len(r.Categories) == 0 {
+		return false
+	}
+
+	// excluded categories
+	if strings.HasPrefix(r.Categories[0], "!") {
+		for _, excluded := range r.Categories {
+			if strings.TrimPrefix(excluded, "!") == string(category) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, allowed := range r.Categories {
+		if allowed == string(category) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate returns the violations of the policy by the managed fields of the object,
+// every entry is evaluated, including the ones of Create operations
+func Evaluate(policy *Policy, object metav1.Object, opts ...utils.DetectOption) ([]Violation, error) {
+	return EvaluateManagedFields(policy, object.GetManagedFields(), opts...)
+}
+
+// EvaluateManagedFields returns the violations of the policy by the managed fields.
+// Of the detection options, the fields of the ignore list are skipped,
+// the managers are classified with the classifier and the entries are parsed with the parse cache.
+func EvaluateManagedFields(policy *Policy, managedFields []metav1.ManagedFieldsEntry, opts ...utils.DetectOption) ([]Violation, error) {
+
+	ignore := utils.IgnoreListOf(opts...)
+	classifier := utils.ManagerClassifierOf(opts...)
+	cache := utils.ParseCacheOf(opts...)
+
+	rules := make([]compiledRule, 0, len(policy.Rules))
+	for idx, rule := range policy.Rules {
+		compiled, err := rule.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", idx, err)
+		}
+		compiled.id = rule.Name
+		if compiled.id == "" {
+			compiled.id = fmt.Sprintf("rules[%d]", idx)
+		}
+		rules = append(rules, compiled)
+	}
+
+	violations := []Violation{}
+
+	for idx, managedField := range managedFields {
+		if managedField.FieldsV1 == nil {
+			continue
+		}
+
+		paths, err := cache.ParseFieldsV1(managedField.FieldsV1)
+		if err != nil {
+			return nil, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err)
+		}
+
+		category := classifier.Classify(managedField.Manager)
+
+		for _, fieldPath := range paths {
+			if ignore.Ignores(managedField, fieldPath) {
+				continue
+			}
+			for _, rule := range rules {
+				if !rule.matches(fieldPath) {
+					continue
+				}
+				// first matching rule decides
+				if rule.Action != Allow && !rule.allows(managedField.Manager, category) {
+					violations = append(violations, Violation{
+						Rule:      rule.id,
+						Action:    rule.Action,
+						Manager:   managedField.Manager,
+						Category:  category,
+						Path:      fieldPath,
+						Index:     idx,
+						Operation: managedField.Operation,
+						Time:      managedField.Time,
+					})
+				}
+				break
+			}
+		}
+	}
+
+	return violations, nil
+}
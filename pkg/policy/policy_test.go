@@ -0,0 +1,311 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+const optimizerPolicy = `
+apiVersion: managedfields/v1alpha1
+kind: OwnershipPolicy
+rules:
+- name: annotations-are-free
+  paths:
+  - metadata.annotations
+  action: allow
+- name: optimizer-owns-resources
+  paths:
+  - spec.template.spec.containers[*].resources
+  managers:
+  - stormforge-*
+  action: deny
+`
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		document    string
+		expectedErr string
+	}{
+		{
+			desc:     "valid policy",
+			document: optimizerPolicy,
+		},
+		{
+			desc: "invalid policy",
+			document: `
+apiVersion: v1
+kind: OwnershipPolicy
+rules:
+- paths:
+  - spec.containers[nginx
+  managers:
+  - "[stormforge"
+  action: block
+`,
+			expectedErr: `apiVersion must be "managedfields/v1alpha1", got "v1"
+rules[0]: action must be one of allow, warn, deny or report, got "block"
+invalid path "spec.containers[nginx": unterminated [
+invalid manager pattern "[stormforge": syntax error in pattern`,
+		},
+		{
+			desc: "invalid categories",
+			document: `
+apiVersion: managedfields/v1alpha1
+kind: OwnershipPolicy
+rules:
+- paths:
+  - spec
+  categories:
+  - robot
+  - "!human"
+  action: deny
+`,
+			expectedErr: `rules[0]: unknown manager category "robot"
+categories must be all allowed or all excluded with "!"`,
+		},
+		{
+			desc: "unknown field",
+			document: `
+apiVersion: managedfields/v1alpha1
+kind: OwnershipPolicy
+rules:
+- path: spec
+  action: deny
+`,
+			expectedErr: `parsing policy: error unmarshaling JSON: while decoding JSON: json: unknown field "path"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			_, err := Parse([]byte(tc.document))
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policy, err := Parse([]byte(optimizerPolicy))
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "stormforge-optimizer",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecWithContainerArgument(),
+					Manager:    "helm",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+			},
+		},
+	}
+
+	violations, err := Evaluate(policy, deployment)
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+
+	assert.Equal(t, "optimizer-owns-resources", violations[0].Rule)
+	assert.Equal(t, Deny, violations[0].Action)
+	assert.Equal(t, "kubectl-client-side-apply", violations[0].Manager)
+	assert.Equal(t, 1, violations[0].Index)
+	assert.Equal(t, "spec.template.spec.containers[nginx].resources.limits", violations[0].Path.Human())
+}
+
+func TestEvaluateIgnoreList(t *testing.T) {
+	policy, err := Parse([]byte(optimizerPolicy))
+	require.NoError(t, err)
+
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+		},
+	}
+
+	violations, err := EvaluateManagedFields(policy, managedFields)
+	require.NoError(t, err)
+	assert.Len(t, violations, 1)
+
+	ignore, err := utils.NewIgnoreList(utils.IgnoreRule{Managers: []string{"kubectl-client-side-apply"}, Paths: []string{"**.limits"}})
+	require.NoError(t, err)
+
+	violations, err = EvaluateManagedFields(policy, managedFields, utils.WithIgnoreList(ignore))
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestEvaluateParseCache(t *testing.T) {
+	policy, err := Parse([]byte(optimizerPolicy))
+	require.NoError(t, err)
+
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+		},
+	}
+
+	expected, err := EvaluateManagedFields(policy, managedFields)
+	require.NoError(t, err)
+
+	cache := utils.NewParseCache(0)
+	for i := 0; i < 2; i++ {
+		violations, err := EvaluateManagedFields(policy, managedFields, utils.WithParseCache(cache))
+		require.NoError(t, err)
+		assert.Equal(t, expected, violations)
+	}
+	assert.Equal(t, uint64(1), cache.Stats().Hits)
+}
+
+func TestEvaluateVersionedManagers(t *testing.T) {
+	policy, err := Parse([]byte(`
+apiVersion: managedfields/v1alpha1
+kind: OwnershipPolicy
+rules:
+- paths:
+  - spec.template.spec.containers[*].resources
+  managers:
+  - helm
+  action: deny
+`))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc       string
+		manager    string
+		violations int
+	}{
+		{desc: "manager as written", manager: "helm", violations: 0},
+		{desc: "versioned manager", manager: "helm-v3.15.2", violations: 0},
+		{desc: "other manager", manager: "helmfile", violations: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			violations, err := EvaluateManagedFields(policy, []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+					Manager:    tc.manager,
+					Operation:  "Update",
+				},
+			})
+			require.NoError(t, err)
+			assert.Len(t, violations, tc.violations)
+		})
+	}
+}
+
+func TestEvaluateCategories(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "stormforge-optimizer",
+			Operation:  "Apply",
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+			Manager:    "kubectl-edit",
+			Operation:  "Update",
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "vpa-updater",
+			Operation:  "Update",
+		},
+	}
+
+	testCases := []struct {
+		desc       string
+		categories string
+		expected   []string
+	}{
+		{
+			desc:       "humans may not override the optimizer",
+			categories: `["!human"]`,
+			expected:   []string{"kubectl-edit human spec.template.spec.containers[nginx].resources.limits"},
+		},
+		{
+			desc:       "only autoscalers may override the optimizer",
+			categories: `["autoscaler"]`,
+			expected:   []string{"kubectl-edit human spec.template.spec.containers[nginx].resources.limits"},
+		},
+		{
+			desc:       "only system controllers may override the optimizer",
+			categories: `["system"]`,
+			expected: []string{
+				"kubectl-edit human spec.template.spec.containers[nginx].resources.limits",
+				"vpa-updater autoscaler spec.template.spec.containers[nginx].resources.requests",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			policy, err := Parse([]byte(`
+apiVersion: managedfields/v1alpha1
+kind: OwnershipPolicy
+rules:
+- paths:
+  - spec.template.spec.containers[*].resources
+  managers:
+  - stormforge-*
+  categories: ` + tc.categories + `
+  action: deny
+`))
+			require.NoError(t, err)
+
+			violations, err := EvaluateManagedFields(policy, managedFields)
+			require.NoError(t, err)
+
+			actual := []string{}
+			for _, violation := range violations {
+				actual = append(actual, fmt.Sprintf("%s %s %s", violation.Manager, violation.Category, violation.Path.Human()))
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+// Options of the rendering
+type Options struct {
+	// Color renders every manager with its own ANSI color
+	Color bool
+}
+
+// Owner is a manager owning a field, with the operation and time of its entry
+type Owner struct {
+	Manager     string
+	Operation   metav1.ManagedFieldsOperationType
+	Subresource string
+	Time        *metav1.Time
+}
+
+// node is a level of the field tree, the owners are set on the owned fields
+type node struct {
+	element  utils.PathElement
+	children map[utils.PathElement]*node
+	owners   []Owner
+}
+
+var palette = []string{"32", "33", "34", "35", "36", "31", "92", "93", "94", "95", "96", "91"}
+
+const resetColor = "\x1b[0m"
+
+// Tree renders the field tree of the managed fields, each owned field annotated
+// with its owning managers, operation and time, e.g.
+//
+//	spec
+//	└── template
+//	    └── spec
+//	        └── containers
+//	            └── [nginx]
+//	                └── resources
+//	                    └── requests  original-manager (Update 2024-06-17T19:56:27Z)
+func Tree(w io.Writer, managedFields []metav1.ManagedFieldsEntry, opts Options) error {
+
+	root, colors, err := buildTree(managedFields)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range root.sortedChildren() {
+		writeNode(w, child, "", "", colors, opts)
+	}
+
+	return nil
+}
+
+// Table renders one row per owned field and owner, with aligned columns
+func Table(w io.Writer, managedFields []metav1.ManagedFieldsEntry, opts Options) error {
+
+	root, colors, err := buildTree(managedFields)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tMANAGER\tOPERATION\tSUBRESOURCE\tTIME")
+
+	var walk func(n *node, path utils.FieldPath)
+	walk = func(n *node, path utils.FieldPath) {
+		for _, owner := range n.owners {
+			subresource := owner.Subresource
+			if subresource == "" {
+				subresource = "<none>"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", path.Human(), colorize(owner.Manager, colors, opts),
+				owner.Operation, subresource, formatTime(owner.Time))
+		}
+		for _, child := range n.sortedChildren() {
+			walk(child, append(path[:len(path):len(path)], child.element))
+		}
+	}
+	walk(root, utils.FieldPath{})
+
+	return tw.Flush()
+}
+
+// Helper function building the field tree of the managed fields
+// and the color of every manager
+func buildTree(managedFields []metav1.ManagedFieldsEntry) (*node, map[string]string, error) {
+
+	root := &node{children: map[utils.PathElement]*node{}}
+	managers := []string{}
+
+	for idx, managedField := range managedFields {
+		paths, err := utils.ParseFieldsV1(managedField.FieldsV1)
+		if err != nil {
+			return nil, nil, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err)
+		}
+
+		managers = append(managers, managedField.Manager)
+		owner := Owner{
+			Manager:     managedField.Manager,
+			Operation:   managedField.Operation,
+			Subresource: managedField.Subresource,
+			Time:        managedField.Time,
+		}
+
+		for _, path := range paths {
+			current := root
+			for _, element := range path {
+				// "." is the parent itself
+				if element.Kind == utils.SelfElement {
+					continue
+				}
+				child, found := current.children[element]
+				if !found {
+					child = &node{element: element, children: map[utils.PathElement]*node{}}
+					current.children[element] = child
+				}
+				current = child
+			}
+			current.owners = append(current.owners, owner)
+		}
+	}
+
+	colors := map[string]string{}
+	managers = utils.MakeUnique(managers)
+	sort.Strings(managers)
+	for idx, manager := range managers {
+		colors[manager] = "\x1b[" + palette[idx%len(palette)] + "m"
+	}
+
+	return root, colors, nil
+}
+
+func writeNode(w io.Writer, n *node, prefix, connector string, colors map[string]string, opts Options) {
+
+	label := n.element.Human()
+	if n.element.Kind != utils.FieldElement {
+		label = "[" + label + "]"
+	}
+
+	owners := make([]string, 0, len(n.owners))
+	for _, owner := range n.owners {
+		owners = append(owners, fmt.Sprintf("%s (%s %s)", colorize(owner.Manager, colors, opts), owner.Operation, formatTime(owner.Time)))
+	}
+	if len(owners) > 0 {
+		label += "  " + strings.Join(owners, ", ")
+	}
+
+	fmt.Fprintln(w, prefix+connector+label)
+
+	childPrefix := prefix
+	switch connector {
+	case "├── ":
+		childPrefix += "│   "
+	case "└── ":
+		childPrefix += "    "
+	}
+
+	children := n.sortedChildren()
+	for idx, child := range children {
+		childConnector := "├── "
+		if idx == len(children)-1 {
+			childConnector = "└── "
+		}
+		writeNode(w, child, childPrefix, childConnector, colors, opts)
+	}
+}
+
+// sortedChildren returns the children sorted by their FieldsV1 key
+func (n *node) sortedChildren() []*node {
+	children := make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return utils.FieldPath{children[i].element}.String() < utils.FieldPath{children[j].element}.String()
+	})
+	return children
+}
+
+func colorize(manager string, colors map[string]string, opts Options) string {
+	if !opts.Color {
+		return manager
+	}
+	return colors[manager] + manager + resetColor
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+func managedFields() []metav1.ManagedFieldsEntry {
+	return []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecWithContainerArgument(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "original-manager",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-18T19:56:27Z")},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		render         func(*bytes.Buffer) error
+		expectedOutput string
+	}{
+		{
+			desc: "tree",
+			render: func(out *bytes.Buffer) error {
+				return Tree(out, managedFields(), Options{})
+			},
+			expectedOutput: `
+metadata
+└── annotations
+    ├── kubernetes.io/change-cause  kubectl-client-side-apply (Update 2024-06-17T19:56:27Z)
+    ├── stormforge.io/last-updated  kubectl-client-side-apply (Update 2024-06-17T19:56:27Z)
+    └── stormforge.io/recommendation-url  kubectl-client-side-apply (Update 2024-06-17T19:56:27Z)
+spec
+└── template
+    └── spec
+        └── containers
+            └── [nginx]
+                ├── args  kubectl-client-side-apply (Update 2024-06-17T19:56:27Z)
+                ├── command  kubectl-client-side-apply (Update 2024-06-17T19:56:27Z)
+                └── resources
+                    └── requests  original-manager (Apply 2024-06-18T19:56:27Z)
+`,
+		},
+		{
+			desc: "table",
+			render: func(out *bytes.Buffer) error {
+				return Table(out, managedFields(), Options{})
+			},
+			expectedOutput: `
+PATH                                                     MANAGER                    OPERATION  SUBRESOURCE  TIME
+metadata.annotations.kubernetes.io/change-cause          kubectl-client-side-apply  Update     <none>       2024-06-17T19:56:27Z
+metadata.annotations.stormforge.io/last-updated          kubectl-client-side-apply  Update     <none>       2024-06-17T19:56:27Z
+metadata.annotations.stormforge.io/recommendation-url    kubectl-client-side-apply  Update     <none>       2024-06-17T19:56:27Z
+spec.template.spec.containers[nginx].args                kubectl-client-side-apply  Update     <none>       2024-06-17T19:56:27Z
+spec.template.spec.containers[nginx].command             kubectl-client-side-apply  Update     <none>       2024-06-17T19:56:27Z
+spec.template.spec.containers[nginx].resources.requests  original-manager           Apply      <none>       2024-06-18T19:56:27Z
+`,
+		},
+		{
+			desc: "colored tree",
+			render: func(out *bytes.Buffer) error {
+				return Tree(out, managedFields()[1:], Options{Color: true})
+			},
+			expectedOutput: `
+spec
+└── template
+    └── spec
+        └── containers
+            └── [nginx]
+                └── resources
+                    └── requests  ` + "\x1b[32moriginal-manager\x1b[0m" + ` (Apply 2024-06-18T19:56:27Z)
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			assert.NoError(t, tc.render(out))
+			assert.Equal(t, strings.TrimPrefix(tc.expectedOutput, "\n"), out.String())
+		})
+	}
+}
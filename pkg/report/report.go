@@ -0,0 +1,171 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"managedfields/pkg/utils"
+)
+
+const (
+	// APIVersion is the version of the report format, it changes on incompatible changes only,
+	// new fields are added to the current version
+	APIVersion = "managedfields/v1"
+	// Kind is the kind of the reports
+	Kind = "ConflictReport"
+)
+
+// schema is the JSON Schema of the report format, published as schema/v1.json
+//
+//go:embed schema/v1.json
+var schema []byte
+
+// Schema returns the JSON Schema of the report format
+func Schema() []byte {
+	return schema
+}
+
+// Report is the stable, versioned format of ownership and conflict analysis results
+type Report struct {
+	APIVersion  string       `json:"apiVersion"`
+	Kind        string       `json:"kind"`
+	GeneratedAt *metav1.Time `json:"generatedAt,omitempty"`
+	Objects     []Object     `json:"objects"`
+}
+
+// Object is the analysis of the managed fields of one object for an original manager
+type Object struct {
+	APIVersion      string `json:"apiVersion"`
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	OriginalManager string `json:"originalManager"`
+	// Overwritten and ExternalManager are the results of DetectExternalManager
+	Overwritten     bool       `json:"overwritten"`
+	ExternalManager string     `json:"externalManager,omitempty"`
+	Conflicts       []Conflict `json:"conflicts"`
+}
+
+// Conflict is a field of the original manager also written by an external manager
+type Conflict struct {
+	ExternalManager string `json:"externalManager"`
+	// Category is the category of the external manager, e.g. human or gitops
+	Category string `json:"category,omitempty"`
+	// Kind is the kind of conflict of the operations of the managers, e.g. update-stole-apply
+	Kind string `json:"kind,omitempty"`
+	// Path is the human readable path, e.g. spec.template.spec.containers[nginx].resources.requests
+	Path string `json:"path"`
+	// FieldPath is the JSON path, as returned by FieldsV1ToJSONPaths
+	FieldPath   string       `json:"fieldPath"`
+	Operation   string       `json:"operation"`
+	Subresource string       `json:"subresource,omitempty"`
+	APIVersion  string       `json:"apiVersion,omitempty"`
+	Time        *metav1.Time `json:"time,omitempty"`
+	Overwritten bool         `json:"overwritten"`
+	// Value is the live value of the field, when known
+	Value interface{} `json:"value,omitempty"`
+	// Actor is who wrote the field, when known from the audit logs
+	Actor *Actor `json:"actor,omitempty"`
+}
+
+// Actor is the requester of a write
+type Actor struct {
+	Username string   `json:"username"`
+	UID      string   `json:"uid,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// ServiceAccount is namespace/name when the user is a service account
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Impersonator is the user impersonating Username
+	Impersonator string   `json:"impersonator,omitempty"`
+	SourceIPs    []string `json:"sourceIPs,omitempty"`
+	UserAgent    string   `json:"userAgent,omitempty"`
+	AuditID      string   `json:"auditID,omitempty"`
+}
+
+// New returns an empty report
+func New() *Report {
+	return &Report{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Objects:    []Object{},
+	}
+}
+
+// NewObject returns the report of the object for the conflicts detected with DetectFieldConflicts
+func NewObject(object runtime.Object, originalManager string, conflicts []utils.FieldConflict) (Object, error) {
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return Object{}, err
+	}
+
+	apiVersion, kind := object.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+
+	result := Object{
+		APIVersion:      apiVersion,
+		Kind:            kind,
+		Namespace:       accessor.GetNamespace(),
+		Name:            accessor.GetName(),
+		OriginalManager: originalManager,
+		Conflicts:       make([]Conflict, 0, len(conflicts)),
+	}
+
+	// same as DetectExternalManager, the last conflict is the latest external manager
+	for _, conflict := range conflicts {
+		result.ExternalManager = conflict.ExternalManager
+		if conflict.Overwritten {
+			result.Overwritten = true
+		}
+		result.Conflicts = append(result.Conflicts, NewConflict(conflict))
+	}
+
+	return result, nil
+}
+
+// NewConflict returns the report of a conflict
+func NewConflict(conflict utils.FieldConflict) Conflict {
+	return Conflict{
+		ExternalManager: conflict.ExternalManager,
+		Category:        string(conflict.Category),
+		Kind:            string(conflict.Kind),
+		Path:            conflict.Path.Human(),
+		FieldPath:       conflict.Path.String(),
+		Operation:       string(conflict.Operation),
+		Subresource:     conflict.Subresource,
+		APIVersion:      conflict.APIVersion,
+		Time:            conflict.Time,
+		Overwritten:     conflict.Overwritten,
+		Value:           conflict.Value,
+	}
+}
+
+// MarshalJSON returns the indented JSON of the report
+func MarshalJSON(report *Report) ([]byte, error) {
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// MarshalYAML returns the YAML of the report
+func MarshalYAML(report *Report) ([]byte, error) {
+	return yaml.Marshal(report)
+}
+
+// Unmarshal parses a JSON or YAML report, rejecting other versions of the format.
+// Fields are added to a version without changing it, the unknown fields are ignored.
+func Unmarshal(data []byte) (*Report, error) {
+	report := &Report{}
+	if err := yaml.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("parsing report: %w", err)
+	}
+
+	if report.APIVersion != APIVersion || report.Kind != Kind {
+		return nil, fmt.Errorf("unsupported report %s %s, expected %s %s", report.APIVersion, report.Kind, APIVersion, Kind)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+func testReport(t *testing.T) *Report {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+				},
+			},
+		},
+	}
+
+	conflicts := utils.DetectFieldConflicts("original-manager", deployment.ManagedFields)
+	object, err := NewObject(deployment, "original-manager", conflicts)
+	require.NoError(t, err)
+
+	report := New()
+	report.Objects = append(report.Objects, object)
+	return report
+}
+
+func TestMarshal(t *testing.T) {
+	report := testReport(t)
+
+	data, err := MarshalYAML(report)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: managedfields/v1
+kind: ConflictReport
+objects:
+- apiVersion: apps/v1
+  conflicts:
+  - apiVersion: apps/v1
+    category: human
+    externalManager: kubectl-client-side-apply
+    fieldPath: /spec/template/spec/containers/[{"name":"nginx"}]/resources/requests
+    kind: update-vs-update
+    operation: Update
+    overwritten: true
+    path: spec.template.spec.containers[nginx].resources.requests
+    time: "2044-06-17T19:56:27Z"
+  externalManager: kubectl-client-side-apply
+  kind: Deployment
+  name: nginx
+  namespace: default
+  originalManager: original-manager
+  overwritten: true
+`, string(data))
+
+	// times are parsed in the local time zone, so the round trips are compared marshalled
+	fromYAML, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, data, must(MarshalYAML(fromYAML)))
+
+	data, err = MarshalJSON(report)
+	require.NoError(t, err)
+
+	fromJSON, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, data, must(MarshalJSON(fromJSON)))
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestUnmarshalOtherVersion(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"apiVersion": "managedfields/v2", "kind": "ConflictReport", "objects": []}`))
+	assert.EqualError(t, err, "unsupported report managedfields/v2 ConflictReport, expected managedfields/v1 ConflictReport")
+}
+
+// the fields added to v1 by newer versions of the package are ignored
+func TestUnmarshalUnknownFields(t *testing.T) {
+	report, err := Unmarshal([]byte(`
+apiVersion: managedfields/v1
+kind: ConflictReport
+source: backup
+objects:
+- apiVersion: apps/v1
+  kind: Deployment
+  name: nginx
+  originalManager: stormforge-optimizer
+  overwritten: false
+  cluster: production
+  conflicts:
+  - externalManager: kubectl-edit
+    path: spec.replicas
+    fieldPath: /spec/replicas
+    operation: Update
+    overwritten: false
+    category: robot
+    severity: high
+`))
+	require.NoError(t, err)
+	require.Len(t, report.Objects, 1)
+	require.Len(t, report.Objects[0].Conflicts, 1)
+	assert.Equal(t, "kubectl-edit", report.Objects[0].Conflicts[0].ExternalManager)
+	assert.Equal(t, "robot", report.Objects[0].Conflicts[0].Category)
+
+	// and the schema accepts them
+	assert.NotContains(t, string(Schema()), `"additionalProperties": false`)
+	assert.NotContains(t, string(Schema()), `"enum"`)
+}
+
+// the published schema must describe every field of the structs
+func TestSchema(t *testing.T) {
+	var document struct {
+		Properties map[string]interface{} `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(Schema(), &document))
+
+	assert.Equal(t, jsonFields(Report{}), keys(document.Properties))
+	assert.Equal(t, jsonFields(Object{}), keys(document.Defs["object"].Properties))
+	assert.Equal(t, jsonFields(Conflict{}), keys(document.Defs["conflict"].Properties))
+	assert.Equal(t, jsonFields(Actor{}), keys(document.Defs["actor"].Properties))
+}
+
+func jsonFields(v interface{}) []string {
+	fields := []string{}
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func keys(m map[string]interface{}) []string {
+	result := []string{}
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
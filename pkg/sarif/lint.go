@@ -0,0 +1,286 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/policy"
+	"managedfields/pkg/utils"
+)
+
+// Lint evaluates the policy on the objects of the manifest files, or of the YAML and JSON files
+// of the directories, and returns the violations as a SARIF log. Each violation is located
+// at the line of the field in the manifest, or at the managed fields entry when the field is not in it.
+func Lint(p *policy.Policy, paths ...string) (*Log, error) {
+	return LintWithOptions(p, paths)
+}
+
+// LintWithOptions is Lint evaluating the policy with the detection options, e.g. an ignore list
+func LintWithOptions(p *policy.Policy, paths []string, opts ...utils.DetectOption) (*Log, error) {
+
+	results := []Result{}
+
+	for _, path := range paths {
+		filenames, err := manifestFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filename := range filenames {
+			fileResults, err := lintFile(p, filename, opts)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			results = append(results, fileResults...)
+		}
+	}
+
+	return NewLog(results), nil
+}
+
+// Helper function returning the file, or the YAML and JSON files of the directory
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	filenames := []string{}
+	err = filepath.WalkDir(path, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				filenames = append(filenames, filename)
+			}
+		}
+		return nil
+	})
+
+	return filenames, err
+}
+
+func lintFile(p *policy.Policy, filename string, opts []utils.DetectOption) ([]Result, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		document := &yaml.Node{}
+		if err := decoder.Decode(document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		if len(document.Content) == 0 {
+			continue
+		}
+
+		for _, objectNode := range objectNodes(document.Content[0]) {
+			managedFields, err := decodeManagedFields(objectNode)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", objectNode.Line, err)
+			}
+
+			violations, err := policy.EvaluateManagedFields(p, managedFields, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", objectNode.Line, err)
+			}
+
+			for _, violation := range violations {
+				results = append(results, Result{
+					RuleID: violation.Rule,
+					Level:  Level(violation.Action),
+					Message: Message{Text: fmt.Sprintf("%s is not allowed to own %s",
+						violation.Manager, violation.Path.Human())},
+					Locations: []Location{location(filename, objectNode, violation)},
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Helper function returning the objects of a document, the items of a List or the document itself
+func objectNodes(node *yaml.Node) []*yaml.Node {
+	if kind := mappingValue(node, "kind"); kind != nil && strings.HasSuffix(kind.Value, "List") {
+		if items := mappingValue(node, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			return items.Content
+		}
+	}
+	return []*yaml.Node{node}
+}
+
+func decodeManagedFields(objectNode *yaml.Node) ([]metav1.ManagedFieldsEntry, error) {
+	var object interface{}
+	if err := objectNode.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	// the managed fields are decoded from JSON, as they are by the API machinery
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	return metadata.ManagedFields, nil
+}
+
+// Helper function locating the violation in the file
+func location(filename string, objectNode *yaml.Node, violation policy.Violation) Location {
+
+	node := locate(objectNode, violation.Path)
+	if node == nil {
+		entryPath := utils.FieldPath{
+			{Kind: utils.FieldElement, Value: "metadata"},
+			{Kind: utils.FieldElement, Value: "managedFields"},
+			{Kind: utils.IndexElement, Value: strconv.Itoa(violation.Index)},
+		}
+		node = locate(objectNode, entryPath)
+	}
+	if node == nil {
+		node = objectNode
+	}
+
+	return Location{PhysicalLocation: PhysicalLocation{
+		ArtifactLocation: ArtifactLocation{URI: filepath.ToSlash(filename)},
+		Region:           &Region{StartLine: node.Line, StartColumn: node.Column},
+	}}
+}
+
+// Helper function returning the node of the field path in the object, nil if it is not in it.
+// Fields are located at their key.
+func locate(objectNode *yaml.Node, path utils.FieldPath) *yaml.Node {
+
+	node := objectNode
+	position := objectNode
+
+	for _, element := range path {
+		switch element.Kind {
+		case utils.SelfElement:
+			continue
+		case utils.FieldElement:
+			position = mappingKey(node, element.Value)
+			node = mappingValue(node, element.Value)
+		case utils.KeyElement:
+			node = keyedItem(node, element.Value)
+			position = node
+		case utils.ValueElement:
+			node = setItem(node, element.Human())
+			position = node
+		case utils.IndexElement:
+			node = indexedItem(node, element.Value)
+			position = node
+		}
+
+		if node == nil {
+			return nil
+		}
+	}
+
+	return position
+}
+
+func mappingKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Helper function returning the item of the associative list with the JSON encoded key
+func keyedItem(node *yaml.Node, rawKey string) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var key map[string]interface{}
+	if err := json.Unmarshal([]byte(rawKey), &key); err != nil {
+		return nil
+	}
+
+	for _, item := range node.Content {
+		matches := true
+		for name, value := range key {
+			field := mappingValue(item, name)
+			if field == nil || field.Value != fmt.Sprint(value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return item
+		}
+	}
+	return nil
+}
+
+func setItem(node *yaml.Node, value string) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode && item.Value == value {
+			return item
+		}
+	}
+	return nil
+}
+
+func indexedItem(node *yaml.Node, index string) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
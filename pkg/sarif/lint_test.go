@@ -0,0 +1,68 @@
+package sarif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"managedfields/pkg/policy"
+)
+
+func TestLint(t *testing.T) {
+	p, err := policy.ParseFile("testdata/policy.yaml")
+	require.NoError(t, err)
+
+	log, err := Lint(p, "testdata/manifests")
+	require.NoError(t, err)
+
+	data, err := Marshal(log)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "managed-fields-utils",
+          "informationUri": "https://github.com/brito-rafa/managed-fields-utils",
+          "rules": [
+            {"id": "optimizer-owns-resources", "shortDescription": {"text": "Ownership policy rule optimizer-owns-resources"}},
+            {"id": "replicas-by-autoscaler", "shortDescription": {"text": "Ownership policy rule replicas-by-autoscaler"}}
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "replicas-by-autoscaler",
+          "level": "warning",
+          "message": {"text": "kubectl-client-side-apply is not allowed to own spec.replicas"},
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {"uri": "testdata/manifests/deployment.yaml"},
+                "region": {"startLine": 7, "startColumn": 5}
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "optimizer-owns-resources",
+          "level": "error",
+          "message": {"text": "kubectl-client-side-apply is not allowed to own spec.template.spec.containers[nginx].resources.limits"},
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {"uri": "testdata/manifests/deployment.yaml"},
+                "region": {"startLine": 30, "startColumn": 11}
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`, string(data))
+}
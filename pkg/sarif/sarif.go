@@ -0,0 +1,139 @@
+package sarif
+
+import (
+	"encoding/json"
+	"sort"
+
+	"managedfields/pkg/policy"
+)
+
+const (
+	// Version of the SARIF format
+	Version = "2.1.0"
+	// SchemaURI of the SARIF format
+	SchemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	toolName           = "managed-fields-utils"
+	toolInformationURI = "https://github.com/brito-rafa/managed-fields-utils"
+)
+
+// Log is a SARIF 2.1.0 log, limited to the properties used to report policy violations
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is a single run of the tool
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool and the rules it checks
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool component
+type Driver struct {
+	Name           string          `json:"name"`
+	InformationURI string          `json:"informationUri,omitempty"`
+	Rules          []ReportingRule `json:"rules,omitempty"`
+}
+
+// ReportingRule is a rule of the policy
+type ReportingRule struct {
+	ID               string  `json:"id"`
+	ShortDescription Message `json:"shortDescription"`
+}
+
+// Result is a violation
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+}
+
+// Message is a plain text message
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location of a result
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the URI of a file, relative to the repository root in CI
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a position in a file, 1-based
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Level returns the SARIF level of a policy action
+func Level(action policy.Action) string {
+	switch action {
+	case policy.Deny:
+		return "error"
+	case policy.Warn:
+		return "warning"
+	}
+	return "note"
+}
+
+// NewLog returns a log with a single run of the tool and its results,
+// the rules are the ones of the results
+func NewLog(results []Result) *Log {
+	ruleIDs := []string{}
+	seen := map[string]struct{}{}
+	for _, result := range results {
+		if _, found := seen[result.RuleID]; !found {
+			seen[result.RuleID] = struct{}{}
+			ruleIDs = append(ruleIDs, result.RuleID)
+		}
+	}
+	sort.Strings(ruleIDs)
+
+	rules := make([]ReportingRule, 0, len(ruleIDs))
+	for _, id := range ruleIDs {
+		rules = append(rules, ReportingRule{
+			ID:               id,
+			ShortDescription: Message{Text: "Ownership policy rule " + id},
+		})
+	}
+
+	if results == nil {
+		results = []Result{}
+	}
+
+	return &Log{
+		Version: Version,
+		Schema:  SchemaURI,
+		Runs: []Run{{
+			Tool: Tool{Driver: Driver{
+				Name:           toolName,
+				InformationURI: toolInformationURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// Marshal returns the indented JSON of the log
+func Marshal(log *Log) ([]byte, error) {
+	return json.MarshalIndent(log, "", "  ")
+}
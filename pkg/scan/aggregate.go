@@ -0,0 +1,183 @@
+package scan
+
+import (
+	"sort"
+
+	"managedfields/pkg/utils"
+)
+
+// aggregator sums the results of the workers, from a single goroutine
+type aggregator struct {
+	files                int
+	objects              int
+	objectsWithConflicts int
+	conflicts            int
+	managers             map[string]*ManagerSummary
+	kinds                map[string]*kindTotals
+	errors               []string
+}
+
+type kindTotals struct {
+	objects              int
+	objectsWithConflicts int
+	paths                map[string]*pathTotals
+}
+
+type pathTotals struct {
+	objects   int
+	conflicts int
+	managers  map[string]struct{}
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{
+		managers: map[string]*ManagerSummary{},
+		kinds:    map[string]*kindTotals{},
+		errors:   []string{},
+	}
+}
+
+func (a *aggregator) add(result fileResult) {
+	a.files++
+	a.errors = append(a.errors, result.errors...)
+
+	for _, object := range result.objects {
+		a.objects++
+
+		kind, found := a.kinds[object.kind]
+		if !found {
+			kind = &kindTotals{paths: map[string]*pathTotals{}}
+			a.kinds[object.kind] = kind
+		}
+		kind.objects++
+
+		if len(object.conflicts) == 0 {
+			continue
+		}
+		a.objectsWithConflicts++
+		kind.objectsWithConflicts++
+
+		// the managers and paths of the object, counted once per object
+		objectManagers := map[string]struct{}{}
+		objectPaths := map[string]struct{}{}
+
+		for _, conflict := range object.conflicts {
+			a.conflicts++
+
+			manager, found := a.managers[conflict.ExternalManager]
+			if !found {
+				manager = &ManagerSummary{Manager: conflict.ExternalManager, Category: conflict.Category}
+				a.managers[conflict.ExternalManager] = manager
+			}
+			manager.Conflicts++
+			if conflict.Overwritten {
+				manager.Overwrites++
+			}
+			if _, seen := objectManagers[conflict.ExternalManager]; !seen {
+				objectManagers[conflict.ExternalManager] = struct{}{}
+				manager.Objects++
+			}
+
+			path := anyItemPath(conflict.Path)
+			totals, found := kind.paths[path]
+			if !found {
+				totals = &pathTotals{managers: map[string]struct{}{}}
+				kind.paths[path] = totals
+			}
+			totals.conflicts++
+			totals.managers[conflict.ExternalManager] = struct{}{}
+			if _, seen := objectPaths[path]; !seen {
+				objectPaths[path] = struct{}{}
+				totals.objects++
+			}
+		}
+	}
+}
+
+// summary returns the sorted summary of the results
+func (a *aggregator) summary() *Summary {
+
+	summary := &Summary{
+		Files:                a.files,
+		Objects:              a.objects,
+		ObjectsWithConflicts: a.objectsWithConflicts,
+		Conflicts:            a.conflicts,
+		Managers:             make([]ManagerSummary, 0, len(a.managers)),
+		Kinds:                make([]KindSummary, 0, len(a.kinds)),
+	}
+
+	for _, manager := range a.managers {
+		summary.Managers = append(summary.Managers, *manager)
+	}
+	sort.Slice(summary.Managers, func(i, j int) bool {
+		mi, mj := summary.Managers[i], summary.Managers[j]
+		if mi.Overwrites != mj.Overwrites {
+			return mi.Overwrites > mj.Overwrites
+		}
+		if mi.Conflicts != mj.Conflicts {
+			return mi.Conflicts > mj.Conflicts
+		}
+		return mi.Manager < mj.Manager
+	})
+
+	for name, kind := range a.kinds {
+		kindSummary := KindSummary{
+			Kind:                 name,
+			Objects:              kind.objects,
+			ObjectsWithConflicts: kind.objectsWithConflicts,
+			Paths:                make([]PathSummary, 0, len(kind.paths)),
+		}
+		for path, totals := range kind.paths {
+			managers := make([]string, 0, len(totals.managers))
+			for manager := range totals.managers {
+				managers = append(managers, manager)
+			}
+			sort.Strings(managers)
+			kindSummary.Paths = append(kindSummary.Paths, PathSummary{
+				Path:      path,
+				Objects:   totals.objects,
+				Conflicts: totals.conflicts,
+				Managers:  managers,
+			})
+		}
+		sort.Slice(kindSummary.Paths, func(i, j int) bool {
+			pi, pj := kindSummary.Paths[i], kindSummary.Paths[j]
+			if pi.Objects != pj.Objects {
+				return pi.Objects > pj.Objects
+			}
+			if pi.Conflicts != pj.Conflicts {
+				return pi.Conflicts > pj.Conflicts
+			}
+			return pi.Path < pj.Path
+		})
+		summary.Kinds = append(summary.Kinds, kindSummary)
+	}
+	sort.Slice(summary.Kinds, func(i, j int) bool {
+		ki, kj := summary.Kinds[i], summary.Kinds[j]
+		if ki.ObjectsWithConflicts != kj.ObjectsWithConflicts {
+			return ki.ObjectsWithConflicts > kj.ObjectsWithConflicts
+		}
+		return ki.Kind < kj.Kind
+	})
+
+	if len(a.errors) > 0 {
+		summary.Errors = append([]string{}, a.errors...)
+		sort.Strings(summary.Errors)
+	}
+
+	return summary
+}
+
+// Helper function returning the human path with any list item instead of the items,
+// e.g. spec.template.spec.containers[*].resources for the resources of every container
+func anyItemPath(path utils.FieldPath) string {
+	generic := make(utils.FieldPath, 0, len(path))
+	for _, element := range path {
+		switch element.Kind {
+		case utils.KeyElement, utils.ValueElement, utils.IndexElement:
+			element = utils.PathElement{Kind: utils.IndexElement, Value: "*"}
+		}
+		generic = append(generic, element)
+	}
+	return generic.Human()
+}
@@ -0,0 +1,304 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"managedfields/pkg/utils"
+)
+
+// Scanner analyzes the managed fields of the objects of exported manifests,
+// e.g. of a cluster backup or of kubectl get -A -o yaml, and aggregates the conflicts
+type Scanner struct {
+	// Workers is the number of files analyzed at once, the number of CPUs when 0
+	Workers int
+	// OriginalManagers are the managers whose fields are checked,
+	// every manager of every object when empty
+	OriginalManagers []string
+	// Options are the options of the detection functions
+	Options []utils.DetectOption
+}
+
+// Summary is the fleet-wide view of the conflicts of the scanned objects.
+// With every manager as original manager, a field written by two managers
+// is counted as a conflict of each one.
+type Summary struct {
+	Files                int `json:"files"`
+	Objects              int `json:"objects"`
+	ObjectsWithConflicts int `json:"objectsWithConflicts"`
+	Conflicts            int `json:"conflicts"`
+	// Managers are the external managers, the most aggressive first:
+	// sorted by overwrites, conflicts and name
+	Managers []ManagerSummary `json:"managers"`
+	// Kinds are the kinds of the objects, sorted by objects with conflicts and kind
+	Kinds []KindSummary `json:"kinds"`
+	// Errors are the files, objects and managed fields entries that could not be analyzed
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ManagerSummary is the conflicts of an external manager
+type ManagerSummary struct {
+	Manager  string                `json:"manager"`
+	Category utils.ManagerCategory `json:"category"`
+	// Objects are the objects where the manager conflicts with an original manager
+	Objects    int `json:"objects"`
+	Conflicts  int `json:"conflicts"`
+	Overwrites int `json:"overwrites"`
+}
+
+// KindSummary is the conflicts of the objects of a kind
+type KindSummary struct {
+	// Kind is the kind and group, e.g. Deployment.apps
+	Kind                 string `json:"kind"`
+	Objects              int    `json:"objects"`
+	ObjectsWithConflicts int    `json:"objectsWithConflicts"`
+	// Paths are the contested paths, the most contested first: sorted by objects, conflicts and path
+	Paths []PathSummary `json:"paths"`
+}
+
+// PathSummary is the conflicts of a path across the objects of a kind
+type PathSummary struct {
+	// Path is the path of the external managers with any list item, e.g. spec.template.spec.containers[*].resources,
+	// the format of the paths of policies and ignore rules
+	Path      string `json:"path"`
+	Objects   int    `json:"objects"`
+	Conflicts int    `json:"conflicts"`
+	// Managers are the external managers writing the path, sorted by name
+	Managers []string `json:"managers"`
+}
+
+// the conflicts of an object, as analyzed by a worker
+type objectResult struct {
+	kind      string
+	conflicts []utils.FieldConflict
+}
+
+// the objects of a file, as analyzed by a worker
+type fileResult struct {
+	objects []objectResult
+	errors  []string
+}
+
+// Scan analyzes the objects of the files, YAML or JSON, and of the .yaml, .yml and .json files
+// of the directories, recursively. The files are analyzed by a pool of Workers goroutines.
+// Files and objects that cannot be analyzed are reported in the errors of the summary,
+// Scan fails only when the files cannot be listed or the context is done.
+func (s *Scanner) Scan(ctx context.Context, paths ...string) (*Summary, error) {
+
+	filenames, err := listFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	files := make(chan string)
+	results := make(chan fileResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filename := range files {
+				results <- s.scanFile(filename)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(files)
+		for _, filename := range filenames {
+			select {
+			case files <- filename:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	a := newAggregator()
+	for result := range results {
+		a.add(result)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.summary(), nil
+}
+
+// Helper function returning the files to scan, sorted
+func listFiles(paths []string) ([]string, error) {
+
+	filenames := []string{}
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			filenames = append(filenames, root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+				filenames = append(filenames, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(filenames)
+
+	return filenames, nil
+}
+
+// scanFile detects the conflicts of every object of the file, the items of lists included
+func (s *Scanner) scanFile(filename string) fileResult {
+
+	result := fileResult{}
+
+	objects, errs := readObjects(filename)
+	for _, err := range errs {
+		result.errors = append(result.errors, fmt.Sprintf("%s: %v", filename, err))
+	}
+
+	for _, object := range objects {
+		objectResult := objectResult{
+			kind:      object.GroupVersionKind().GroupKind().String(),
+			conflicts: []utils.FieldConflict{},
+		}
+
+		// an entry is reported once, whatever the number of original managers
+		reported := map[int]struct{}{}
+
+		for _, manager := range s.originalManagers(object) {
+			conflicts, err := utils.DetectFieldConflictsInObject(manager, object, s.Options...)
+			for _, entryError := range utils.EntryErrors(err) {
+				if _, found := reported[entryError.Index]; found {
+					continue
+				}
+				reported[entryError.Index] = struct{}{}
+				result.errors = append(result.errors, fmt.Sprintf("%s: %s: %v", filename, objectName(object), entryError))
+			}
+			objectResult.conflicts = append(objectResult.conflicts, conflicts...)
+		}
+
+		result.objects = append(result.objects, objectResult)
+	}
+
+	return result
+}
+
+// Helper function returning the original managers of the object
+func (s *Scanner) originalManagers(object *unstructured.Unstructured) []string {
+	if len(s.OriginalManagers) > 0 {
+		return s.OriginalManagers
+	}
+
+	managers := map[string]struct{}{}
+	for _, managedField := range object.GetManagedFields() {
+		managers[managedField.Manager] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(managers))
+	for manager := range managers {
+		sorted = append(sorted, manager)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Helper function reading the objects of a YAML or JSON file, one or more documents,
+// with the items of the lists instead of the lists. The documents that are not objects are
+// reported in the errors, the file is not read further after a syntax error.
+func readObjects(filename string) ([]*unstructured.Unstructured, []error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+
+	objects := []*unstructured.Unstructured{}
+	errs := []error{}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for document := 1; ; document++ {
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			if !errors.Is(err, io.EOF) {
+				errs = append(errs, err)
+			}
+			break
+		}
+		if len(data) == 0 || string(data) == "null" {
+			continue
+		}
+
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(data); err != nil {
+			errs = append(errs, fmt.Errorf("document %d: %w", document, err))
+			continue
+		}
+
+		if !object.IsList() {
+			objects = append(objects, object)
+			continue
+		}
+
+		list, err := object.ToList()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("document %d: %w", document, err))
+			continue
+		}
+		for idx := range list.Items {
+			objects = append(objects, &list.Items[idx])
+		}
+	}
+
+	return objects, errs
+}
+
+func objectName(object *unstructured.Unstructured) string {
+	name := strings.ToLower(object.GetKind()) + "/" + object.GetName()
+	if object.GetNamespace() != "" {
+		name = object.GetNamespace() + "/" + name
+	}
+	return name
+}
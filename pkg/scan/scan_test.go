@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"managedfields/pkg/utils"
+)
+
+func TestScan(t *testing.T) {
+	scanner := &Scanner{Workers: 2, OriginalManagers: []string{"stormforge-optimizer"}}
+
+	summary, err := scanner.Scan(context.Background(), "testdata/backup")
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, summary.Files)
+	assert.Equal(t, 5, summary.Objects)
+	assert.Equal(t, 2, summary.ObjectsWithConflicts)
+	assert.Equal(t, 3, summary.Conflicts)
+
+	assert.Equal(t, []ManagerSummary{
+		{Manager: "kubectl-edit", Category: utils.HumanManager, Objects: 2, Conflicts: 2, Overwrites: 1},
+		{Manager: "vpa-updater", Category: utils.AutoscalerManager, Objects: 1, Conflicts: 1, Overwrites: 1},
+	}, summary.Managers)
+
+	assert.Equal(t, []KindSummary{
+		{
+			Kind:                 "Deployment.apps",
+			Objects:              4,
+			ObjectsWithConflicts: 2,
+			Paths: []PathSummary{
+				{Path: "spec.template.spec.containers[*].resources.requests.cpu", Objects: 2, Conflicts: 2, Managers: []string{"kubectl-edit"}},
+				{Path: "spec.template.spec.containers[*].resources.limits.cpu", Objects: 1, Conflicts: 1, Managers: []string{"vpa-updater"}},
+			},
+		},
+		{
+			Kind:    "ConfigMap",
+			Objects: 1,
+			Paths:   []PathSummary{},
+		},
+	}, summary.Kinds)
+
+	require.Len(t, summary.Errors, 2)
+	assert.Equal(t, `testdata/backup/apps/broken.yml: default/deployment/unsupported: managedFields[1] of kubectl-scale: unsupported fieldsType "FieldsV2"`, summary.Errors[0])
+	assert.Contains(t, summary.Errors[1], "testdata/backup/apps/broken.yml: document 1: Object 'Kind' is missing")
+}
+
+func TestScanEveryManager(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		workers int
+	}{
+		{desc: "one worker", workers: 1},
+		{desc: "default workers", workers: 0},
+		{desc: "more workers than files", workers: 8},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			scanner := &Scanner{Workers: tc.workers}
+
+			summary, err := scanner.Scan(context.Background(), "testdata/backup/deployments.yaml", "testdata/backup/apps")
+			require.NoError(t, err)
+
+			assert.Equal(t, 3, summary.Files)
+			assert.Equal(t, 2, summary.ObjectsWithConflicts)
+			// the fields written by two managers are conflicts of both
+			assert.Equal(t, 6, summary.Conflicts)
+
+			managers := []string{}
+			for _, manager := range summary.Managers {
+				managers = append(managers, fmt.Sprintf("%s %d/%d", manager.Manager, manager.Overwrites, manager.Conflicts))
+			}
+			assert.Equal(t, []string{
+				"stormforge-optimizer 1/3",
+				"kubectl-edit 1/2",
+				"vpa-updater 1/1",
+			}, managers)
+
+			// the broken entry is reported once, not once per original manager
+			require.Len(t, summary.Errors, 2)
+			assert.Equal(t, `testdata/backup/apps/broken.yml: default/deployment/unsupported: managedFields[1] of kubectl-scale: unsupported fieldsType "FieldsV2"`, summary.Errors[0])
+		})
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	scanner := &Scanner{}
+
+	_, err := scanner.Scan(context.Background(), "testdata/missing")
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scanner.Scan(ctx, "testdata/backup")
+	assert.ErrorIs(t, err, context.Canceled)
+}
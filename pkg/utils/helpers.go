@@ -11,90 +11,40 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var (
+	keysRegex    = regexp.MustCompile(`\[\{\".*?\"\}\]`)
+	lastDotRegex = regexp.MustCompile(`/\.$`)
+)
+
 // This function is used to detect two pieces of information:
 // A flag telling if the workload is being overwritten by an external manager **after** original manager
 // it will be true if the external manager wrote the field after original manager by comparing timestamps
 // and if any field was altered by the external manager
 // the second piece of information is the name of the external manager, regardless the flag value
-func DetectExternalManager(originalManager string, managedFields []metav1.ManagedFieldsEntry) (bool, string) {
+func DetectExternalManager(originalManager string, managedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) (bool, string) {
+	overwrittenByExternalManager, otherManager, _ := DetectExternalManagerWithErrors(originalManager, managedFields, opts...)
+	return overwrittenByExternalManager, otherManager
+}
+
+// DetectExternalManagerWithErrors is DetectExternalManager returning the entries it could not analyze as well,
+// see DetectFieldConflictsWithErrors
+func DetectExternalManagerWithErrors(originalManager string, managedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) (bool, string, error) {
 
 	overwrittenByExternalManager := false
 	otherManager := ""
 
-	// First, let's get the latest managed field entry
-	// of the original manager
-
-	managedByOriginalManager, managedFieldsV1, mfTime := DetectManagedFields(originalManager, managedFields)
-
-	if !managedByOriginalManager {
-		return overwrittenByExternalManager, otherManager
-	}
-
-	if managedFieldsV1 == nil {
-		return overwrittenByExternalManager, otherManager
-	}
-
-	// Now, let's get the JSON paths of the managed fields
-	// of the original manager in regex format
-
-	lookFor, err := FieldsV1ToJSONPaths(managedFieldsV1, true)
-	if err != nil {
-		return overwrittenByExternalManager, otherManager
-	}
-
-	if len(lookFor) == 0 {
-		return overwrittenByExternalManager, otherManager
-	}
-
-	mfPattern := strings.Join(lookFor, "|")
-	matchFields := regexp.MustCompile(mfPattern)
+	conflicts, err := DetectFieldConflictsWithErrors(originalManager, managedFields, opts...)
 
-	// managedFields: sorting by time
-	sort.Slice(managedFields, func(i, j int) bool {
-		// Ensure that Time is not nil, just in case
-		if managedFields[i].Time == nil {
-			return false
+	// the conflicts come sorted by time, so the last one
+	// is the latest external manager
+	for _, conflict := range conflicts {
+		otherManager = conflict.ExternalManager
+		if conflict.Overwritten {
+			overwrittenByExternalManager = true
 		}
-		if managedFields[j].Time == nil {
-			return true
-		}
-		// Compare the time values
-		return managedFields[i].Time.Before(managedFields[j].Time)
-	})
-
-	for _, managedField := range managedFields {
-		if managedField.FieldsV1 == nil {
-			continue
-		}
-		// we want only updates, not creation
-		if managedField.Operation == "Create" {
-			continue
-		}
-		// we ignore the original manager
-		if managedField.Manager == originalManager {
-			continue
-		}
-
-		// normalize the managed fields V1 into JSON Path
-		managedFieldsAsJSONPaths, err := FieldsV1ToJSONPaths(managedField.FieldsV1)
-		if err != nil {
-			continue
-		}
-
-		// regex match the external manager managed fields
-		for _, mfPath := range managedFieldsAsJSONPaths {
-			if matchFields.MatchString(mfPath) {
-				otherManager = managedField.Manager
-				if managedField.Time.After(mfTime.Time) {
-					overwrittenByExternalManager = true
-				}
-			}
-
-		}
-
 	}
 
-	return overwrittenByExternalManager, otherManager
+	return overwrittenByExternalManager, otherManager, err
 }
 
 // DetectManagedFieldsByStormForge
@@ -103,50 +53,45 @@ func DetectExternalManager(originalManager string, managedFields []metav1.Manage
 
 func DetectManagedFields(originalManager string, managedFields []metav1.ManagedFieldsEntry) (bool, *metav1.FieldsV1, metav1.Time) {
 
-	managedByOriginalManager := false
+	idxLatestField, timeLatestField := latestManagedFieldsEntry(originalManager, managedFields, false)
 
-	var idxLatestField int
-	var timeLatestField = metav1.Time{Time: time.Time{}}
+	if idxLatestField >= 0 {
+		return true, managedFields[idxLatestField].FieldsV1, timeLatestField
+	}
 
-	// not super required, but sorting the managedFields
-	// by time
-	sort.Slice(managedFields, func(i, j int) bool {
-		// Ensure that Time is not nil, just in case
-		if managedFields[i].Time == nil {
-			return false
-		}
-		if managedFields[j].Time == nil {
-			return true
-		}
-		// Compare the time values
-		return managedFields[i].Time.Before(managedFields[j].Time)
-	})
+	return false, nil, timeLatestField
+}
 
-	// detect there is a field managed by originalManager and
-	// the secure the index of last one
-	for idx, managedField := range managedFields {
+// Helper function returning the index of the last entry of the original manager
+// and its time, the index is -1 if there is none.
+// The Create entries are skipped unless includeCreate is set.
+// Entries without time are the oldest, in the order of the list, and their time is the zero time.
+func latestManagedFieldsEntry(originalManager string, managedFields []metav1.ManagedFieldsEntry, includeCreate bool) (int, metav1.Time) {
+
+	idxLatestField := -1
+	var timeLatestField = metav1.Time{Time: time.Time{}}
+
+	// the entries sorted by time, so the last entry
+	// of the original manager is the latest one
+	for _, idx := range sortManagedFieldsByTime(managedFields) {
+		managedField := managedFields[idx]
 		if managedField.FieldsV1 == nil {
 			continue
 		}
-		if managedField.Operation == "Create" {
+		if managedField.Operation == "Create" && !includeCreate {
 			continue
 		}
 		if managedField.Manager == originalManager {
-			managedByOriginalManager = true
-			if managedField.Time == nil || managedField.Time.After(timeLatestField.Time) {
-				idxLatestField = idx
-				timeLatestField = *managedField.Time
-			}
+			idxLatestField = idx
+			timeLatestField = timeOf(managedField)
 		}
 	}
 
-	if managedByOriginalManager {
-		return managedByOriginalManager, managedFields[idxLatestField].FieldsV1, timeLatestField
-	}
-
-	return managedByOriginalManager, nil, timeLatestField
+	return idxLatestField, timeLatestField
 }
 
+// MustParseTime parses an RFC 3339 time and panics when it is invalid,
+// it is meant for fixtures, time.Parse returns the errors of other input
 func MustParseTime(value string) time.Time {
 	parsedTime, err := time.Parse(time.RFC3339, value)
 	if err != nil {
@@ -184,20 +129,7 @@ func FieldsV1ToJSONPaths(fieldsV1 *metav1.FieldsV1, flags ...bool) ([]string, er
 		regExPaths := []string{}
 
 		for _, path := range paths {
-
-			// adding escaled on each /
-			escaped := strings.ReplaceAll(path, "/", `\/`)
-
-			//replacing keys with wildcard
-			re := regexp.MustCompile(`\[\{\".*?\"\}\]`)
-			withoutKeys := re.ReplaceAllString(escaped, `*.*`)
-
-			//replacing the last dot with wildcard
-			re = regexp.MustCompile(`/\.$`)
-			withoutLastDot := re.ReplaceAllString(withoutKeys, `/*.*`)
-
-			regExPaths = append(regExPaths, withoutLastDot)
-
+			regExPaths = append(regExPaths, jsonPathToRegex(path))
 		}
 
 		paths = MakeUnique(regExPaths)
@@ -209,6 +141,43 @@ func FieldsV1ToJSONPaths(fieldsV1 *metav1.FieldsV1, flags ...bool) ([]string, er
 
 }
 
+// Helper function to convert a JSON path into a regular expression
+// matching the same field on any list item
+func jsonPathToRegex(path string) string {
+
+	// adding escaled on each /
+	escaped := strings.ReplaceAll(path, "/", `\/`)
+
+	//replacing keys with wildcard
+	withoutKeys := keysRegex.ReplaceAllString(escaped, `*.*`)
+
+	//replacing the last dot with wildcard
+	withoutLastDot := lastDotRegex.ReplaceAllString(withoutKeys, `/*.*`)
+
+	return withoutLastDot
+}
+
+// Helper function returning the indexes of the managed fields sorted by time, without sorting the list itself.
+// Entries without time are the oldest, entries with the same time keep the order of the list.
+func sortManagedFieldsByTime(managedFields []metav1.ManagedFieldsEntry) []int {
+	indexes := make([]int, len(managedFields))
+	for idx := range indexes {
+		indexes[idx] = idx
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return timeOf(managedFields[indexes[i]]).Time.Before(timeOf(managedFields[indexes[j]]).Time)
+	})
+	return indexes
+}
+
+// Helper function returning the time of the entry, the zero time when it has none
+func timeOf(managedField metav1.ManagedFieldsEntry) metav1.Time {
+	if managedField.Time == nil {
+		return metav1.Time{}
+	}
+	return *managedField.Time
+}
+
 // Helper function to recursively extract paths from the fields map
 func extractPaths(prefix string, m map[string]interface{}, paths *[]string) {
 	for key, val := range m {
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// DefaultParseCacheBytes is the size of the FieldsV1 kept by a ParseCache of size 0, 64 MiB
+const DefaultParseCacheBytes = 64 << 20
+
+// ParseCache keeps the paths and the structured-merge-diff sets of the parsed FieldsV1,
+// keyed by the SHA-256 of their Raw, so the entries that did not change between two versions
+// of an object are parsed once, e.g. by a watcher detecting the conflicts of every update.
+// It is safe for concurrent use and keeps the most recently used FieldsV1 only, within a budget
+// of bytes of their Raw.
+type ParseCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	// most recently used first
+	lru     *list.List
+	entries map[[sha256.Size]byte]*list.Element
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type parseCacheEntry struct {
+	key  [sha256.Size]byte
+	size int
+	// parsed is true once paths is set, the paths of an empty FieldsV1 are nil
+	parsed bool
+	paths  []FieldPath
+	set    *fieldpath.Set
+}
+
+// CacheStats are the statistics of a ParseCache since its creation
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Entries is the number of FieldsV1 currently cached
+	Entries int
+	// Bytes is the size of the Raw of the FieldsV1 currently cached
+	Bytes int
+}
+
+// HitRate returns the ratio of the lookups found in the cache, 0 without lookups
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewParseCache returns a cache of the FieldsV1 whose Raw are maxBytes at most altogether,
+// DefaultParseCacheBytes when maxBytes is 0 or less. The parsed paths and sets take
+// a few times the size of the Raw, the FieldsV1 larger than maxBytes are not cached.
+func NewParseCache(maxBytes int) *ParseCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultParseCacheBytes
+	}
+	return &ParseCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+// ParseFieldsV1 is ParseFieldsV1 with the paths of the cache when the same Raw was already parsed.
+// The paths are shared with the other callers and must not be modified, the slice itself is a copy.
+// The FieldsV1 that cannot be parsed are not cached. A nil cache parses every time.
+func (c *ParseCache) ParseFieldsV1(fieldsV1 *metav1.FieldsV1) ([]FieldPath, error) {
+	if c == nil || fieldsV1 == nil {
+		return ParseFieldsV1(fieldsV1)
+	}
+
+	key := sha256.Sum256(fieldsV1.Raw)
+
+	if entry, found := c.get(key, func(entry *parseCacheEntry) bool { return entry.parsed }); found {
+		return append([]FieldPath{}, entry.paths...), nil
+	}
+
+	// parsed without the lock, concurrent misses of the same Raw parse it more than once
+	paths, err := ParseFieldsV1(fieldsV1)
+	if err != nil {
+		return paths, err
+	}
+
+	c.put(key, len(fieldsV1.Raw), func(entry *parseCacheEntry) {
+		entry.parsed = true
+		entry.paths = paths
+	})
+
+	return append([]FieldPath{}, paths...), nil
+}
+
+// FieldsV1ToSet is FieldsV1ToSet with the set of the cache when the same Raw was already converted.
+// The set is shared with the other callers and must not be modified.
+// The FieldsV1 that cannot be converted are not cached. A nil cache converts every time.
+func (c *ParseCache) FieldsV1ToSet(fieldsV1 *metav1.FieldsV1) (*fieldpath.Set, error) {
+	if c == nil || fieldsV1 == nil {
+		return FieldsV1ToSet(fieldsV1)
+	}
+
+	key := sha256.Sum256(fieldsV1.Raw)
+
+	if entry, found := c.get(key, func(entry *parseCacheEntry) bool { return entry.set != nil }); found {
+		return entry.set, nil
+	}
+
+	set, err := FieldsV1ToSet(fieldsV1)
+	if err != nil {
+		return set, err
+	}
+
+	c.put(key, len(fieldsV1.Raw), func(entry *parseCacheEntry) {
+		entry.set = set
+	})
+
+	return set, nil
+}
+
+// Helper function returning a copy of the cached entry when it has what the caller looks for
+func (c *ParseCache) get(key [sha256.Size]byte, has func(*parseCacheEntry) bool) (parseCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[key]; found {
+		entry := element.Value.(*parseCacheEntry)
+		if has(entry) {
+			c.hits++
+			c.lru.MoveToFront(element)
+			return *entry, true
+		}
+	}
+	c.misses++
+	return parseCacheEntry{}, false
+}
+
+// Helper function updating the cached entry, added when missing,
+// and evicting the least recently used entries beyond the budget
+func (c *ParseCache) put(key [sha256.Size]byte, size int, update func(*parseCacheEntry)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[key]; found {
+		update(element.Value.(*parseCacheEntry))
+		return
+	}
+	if size > c.maxBytes {
+		return
+	}
+
+	entry := &parseCacheEntry{key: key, size: size}
+	update(entry)
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		evicted := oldest.Value.(*parseCacheEntry)
+		delete(c.entries, evicted.key)
+		c.bytes -= evicted.size
+		c.evictions++
+	}
+}
+
+// Stats returns the statistics of the cache, the zero statistics for a nil cache
+func (c *ParseCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions, Entries: c.lru.Len(), Bytes: c.bytes}
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCache(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		maxBytes  int
+		fieldsV1s []*metav1.FieldsV1
+		expected  CacheStats
+	}{
+		{
+			desc:      "same raw",
+			fieldsV1s: []*metav1.FieldsV1{AppsV1ManagedFieldsMetaAndSpec(), AppsV1ManagedFieldsMetaAndSpec()},
+			expected:  CacheStats{Hits: 1, Misses: 1, Entries: 1, Bytes: 466},
+		},
+		{
+			desc:      "different raws",
+			fieldsV1s: []*metav1.FieldsV1{AppsV1ManagedFieldsMetaAndSpec(), AppsV1ManagedFieldsMetaAndSpecRequests()},
+			expected:  CacheStats{Misses: 2, Entries: 2, Bytes: 466 + 208},
+		},
+		{
+			// 466, 208 and 206 bytes, two fit in the budget
+			desc:     "least recently used evicted",
+			maxBytes: 700,
+			fieldsV1s: []*metav1.FieldsV1{
+				AppsV1ManagedFieldsMetaAndSpec(),
+				AppsV1ManagedFieldsMetaAndSpecRequests(),
+				AppsV1ManagedFieldsMetaAndSpec(),
+				AppsV1ManagedFieldsMetaAndSpecLimits(),
+				AppsV1ManagedFieldsMetaAndSpec(),
+				AppsV1ManagedFieldsMetaAndSpecRequests(),
+			},
+			expected: CacheStats{Hits: 2, Misses: 4, Evictions: 2, Entries: 2, Bytes: 466 + 208},
+		},
+		{
+			desc:      "larger than the budget not cached",
+			maxBytes:  400,
+			fieldsV1s: []*metav1.FieldsV1{AppsV1ManagedFieldsMetaAndSpec(), AppsV1ManagedFieldsMetaAndSpec()},
+			expected:  CacheStats{Misses: 2},
+		},
+		{
+			desc:      "invalid raw not cached",
+			fieldsV1s: []*metav1.FieldsV1{{Raw: []byte(`{"spec"}`)}, {Raw: []byte(`{"spec"}`)}},
+			expected:  CacheStats{Misses: 2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			cache := NewParseCache(tc.maxBytes)
+
+			for _, fieldsV1 := range tc.fieldsV1s {
+				paths, err := cache.ParseFieldsV1(fieldsV1)
+				expectedPaths, expectedErr := ParseFieldsV1(fieldsV1)
+				assert.Equal(t, expectedErr, err)
+				assert.Equal(t, expectedPaths, paths)
+			}
+
+			assert.Equal(t, tc.expected, cache.Stats())
+		})
+	}
+}
+
+func TestParseCacheHitRate(t *testing.T) {
+	assert.Equal(t, 0.0, CacheStats{}.HitRate())
+	assert.Equal(t, 0.75, CacheStats{Hits: 3, Misses: 1}.HitRate())
+
+	var cache *ParseCache
+	paths, err := cache.ParseFieldsV1(AppsV1ManagedFieldsMetaAndSpec())
+	require.NoError(t, err)
+	assert.NotEmpty(t, paths)
+	assert.Equal(t, CacheStats{}, cache.Stats())
+}
+
+func TestParseCacheConcurrent(t *testing.T) {
+	cache := NewParseCache(700)
+	fieldsV1s := []*metav1.FieldsV1{
+		AppsV1ManagedFieldsMetaAndSpec(),
+		AppsV1ManagedFieldsMetaAndSpecRequests(),
+		AppsV1ManagedFieldsMetaAndSpecLimits(),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fieldsV1 := fieldsV1s[(i+j)%len(fieldsV1s)]
+				paths, err := cache.ParseFieldsV1(fieldsV1)
+				expected, _ := ParseFieldsV1(fieldsV1)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, paths)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(800), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Entries, 2)
+	assert.LessOrEqual(t, stats.Bytes, 700)
+}
+
+func TestParseCacheSets(t *testing.T) {
+	cache := NewParseCache(0)
+
+	expected, err := FieldsV1ToSet(AppsV1ManagedFieldsMetaAndSpec())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		set, err := cache.FieldsV1ToSet(AppsV1ManagedFieldsMetaAndSpec())
+		require.NoError(t, err)
+		assert.True(t, expected.Equals(set))
+	}
+
+	// the paths of the same FieldsV1 are parsed once too, in the same entry
+	_, err = cache.ParseFieldsV1(AppsV1ManagedFieldsMetaAndSpec())
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 1, Bytes: 466}, cache.Stats())
+
+	_, err = cache.FieldsV1ToSet(&metav1.FieldsV1{Raw: []byte(`{"spec"}`)})
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestDetectFieldConflictsParseCache(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "stormforge-optimizer",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-edit",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+		},
+	}
+
+	cache := NewParseCache(0)
+	expected := DetectFieldConflicts("stormforge-optimizer", managedFields)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, expected, DetectFieldConflicts("stormforge-optimizer", managedFields, WithParseCache(cache)))
+	}
+
+	// every call parses both entries, the first one misses
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 2, Entries: 2, Bytes: 466 + 208}, cache.Stats())
+
+	// the set of the original entry is cached as well
+	cache = NewParseCache(0)
+	expected = DetectFieldConflicts("stormforge-optimizer", managedFields, WithFieldSets())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, expected, DetectFieldConflicts("stormforge-optimizer", managedFields, WithFieldSets(), WithParseCache(cache)))
+	}
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 2, Entries: 2, Bytes: 466 + 208}, cache.Stats())
+}
+
+func TestDiffManagedFieldsParseCache(t *testing.T) {
+	before := []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("stormforge-optimizer", "Apply", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+	}
+	after := append(before, managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"))
+
+	cache := NewParseCache(0)
+	expected, err := DiffManagedFields(before, after)
+	require.NoError(t, err)
+
+	diff, err := DiffManagedFields(before, after, WithParseCache(cache))
+	require.NoError(t, err)
+	assert.Equal(t, expected, diff)
+
+	// the entry of stormforge-optimizer is parsed once
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 2, Bytes: 466 + 208}, cache.Stats())
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConflictKind tells the operations of the original and external managers of a conflict apart:
+// Apply ownership is intent, Update (and Create) ownership is incidental
+type ConflictKind string
+
+const (
+	// ApplyVsApply is a field applied by both managers, their configurations disagree
+	ApplyVsApply ConflictKind = "apply-vs-apply"
+	// UpdateStoleApply is a field applied by the original manager and updated by the external one,
+	// e.g. with kubectl edit or by a controller
+	UpdateStoleApply ConflictKind = "update-stole-apply"
+	// ApplyVsUpdate is a field updated by the original manager and applied by the external one
+	ApplyVsUpdate ConflictKind = "apply-vs-update"
+	// UpdateVsUpdate is a field updated by both managers
+	UpdateVsUpdate ConflictKind = "update-vs-update"
+)
+
+// NewConflictKind returns the kind of conflict between the operations of the original and external managers,
+// Create operations are taken as updates
+func NewConflictKind(original, external metav1.ManagedFieldsOperationType) ConflictKind {
+	originalApply := original == metav1.ManagedFieldsOperationApply
+	externalApply := external == metav1.ManagedFieldsOperationApply
+	switch {
+	case originalApply && externalApply:
+		return ApplyVsApply
+	case originalApply:
+		return UpdateStoleApply
+	case externalApply:
+		return ApplyVsUpdate
+	}
+	return UpdateVsUpdate
+}
+
+// FieldConflict is a field of the original manager
+// that was also written by an external manager
+type FieldConflict struct {
+	OriginalManager string
+	ExternalManager string
+	// Category is the category of the external manager
+	Category ManagerCategory
+	// Kind is the kind of conflict of the operations of the managers
+	Kind ConflictKind
+	// Path of the field as written by the external manager
+	Path        FieldPath
+	Operation   metav1.ManagedFieldsOperationType
+	Subresource string
+	APIVersion  string
+	Time        *metav1.Time
+	// Overwritten is true when the external manager wrote
+	// the field after the original manager
+	Overwritten bool
+	// Value is the live value of the field, set by the detection functions of objects
+	Value interface{}
+}
+
+// DetectFieldConflicts returns every field of the latest entry of the original manager
+// that was also written by an external manager, sorted by the time of the external write.
+// It is the detailed counterpart of DetectExternalManager.
+func DetectFieldConflicts(originalManager string, managedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) []FieldConflict {
+	conflicts, _ := DetectFieldConflictsWithErrors(originalManager, managedFields, opts...)
+	return conflicts
+}
+
+// DetectFieldConflictsWithErrors is DetectFieldConflicts returning the entries it could not analyze as well,
+// joined *EntryError errors with their index and manager. The conflicts are the ones of the other entries,
+// there are none when the entry of the original manager is the broken one.
+func DetectFieldConflictsWithErrors(originalManager string, managedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) ([]FieldConflict, error) {
+
+	// First, let's get the latest managed field entry
+	// of the original manager
+
+	options := newDetectOptions(opts)
+
+	idxOriginal, mfTime := latestManagedFieldsEntry(originalManager, managedFields, options.create)
+
+	if idxOriginal < 0 {
+		return []FieldConflict{}, nil
+	}
+
+	originalEntry := managedFields[idxOriginal]
+	matches, err := newMatcher(originalEntry, options)
+	if err != nil {
+		return []FieldConflict{}, newEntryError(idxOriginal, originalEntry, err)
+	}
+
+	return detectConflicts(originalEntry, mfTime, matches, managedFields, options)
+}
+
+// DetectFieldTakeovers compares two versions of the managed fields of an object,
+// e.g. the old and new objects of an admission request, and returns the fields of the original manager
+// in the old version that an external manager wrote in the new version only
+func DetectFieldTakeovers(originalManager string, oldManagedFields, newManagedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) []FieldConflict {
+	takeovers, _ := DetectFieldTakeoversWithErrors(originalManager, oldManagedFields, newManagedFields, opts...)
+	return takeovers
+}
+
+// DetectFieldTakeoversWithErrors is DetectFieldTakeovers returning the entries it could not analyze as well,
+// see DetectFieldConflictsWithErrors. The errors of each version are prefixed with "old" or "new".
+func DetectFieldTakeoversWithErrors(originalManager string, oldManagedFields, newManagedFields []metav1.ManagedFieldsEntry, opts ...DetectOption) ([]FieldConflict, error) {
+	return detectFieldTakeovers(originalManager, oldManagedFields, newManagedFields, nil, newDetectOptions(opts))
+}
+
+// Helper function returning the takeovers, changed returns true if the field of a conflict
+// has another value in the new version, it is nil without the objects
+func detectFieldTakeovers(originalManager string, oldManagedFields, newManagedFields []metav1.ManagedFieldsEntry, changed func(FieldConflict) bool, options *detectOptions) ([]FieldConflict, error) {
+
+	takeovers := []FieldConflict{}
+
+	idxOriginal, mfTime := latestManagedFieldsEntry(originalManager, oldManagedFields, options.create)
+
+	if idxOriginal < 0 {
+		return takeovers, nil
+	}
+	originalEntry := oldManagedFields[idxOriginal]
+	matches, err := newMatcher(originalEntry, options)
+	if err != nil {
+		return takeovers, prefixErrors("old", newEntryError(idxOriginal, originalEntry, err))
+	}
+
+	errs := []error{}
+
+	// the conflicts already present in the old version are not takeovers,
+	// unless the external manager overwrote the field or changed its value
+	existing := map[string]FieldConflict{}
+	oldConflicts, err := detectConflicts(originalEntry, mfTime, matches, oldManagedFields, options)
+	if err != nil {
+		errs = append(errs, prefixErrors("old", err))
+	}
+	for _, conflict := range oldConflicts {
+		existing[conflict.ExternalManager+" "+conflict.Path.String()] = conflict
+	}
+
+	newConflicts, err := detectConflicts(originalEntry, mfTime, matches, newManagedFields, options)
+	if err != nil {
+		errs = append(errs, prefixErrors("new", err))
+	}
+	for _, conflict := range newConflicts {
+		if oldConflict, found := existing[conflict.ExternalManager+" "+conflict.Path.String()]; found && !rewritten(oldConflict, conflict, changed) {
+			continue
+		}
+		takeovers = append(takeovers, conflict)
+	}
+
+	return takeovers, errors.Join(errs...)
+}
+
+// Helper function returning true if the external manager of a conflict of the old version wrote the field again
+// in the new one: it overwrites the original manager now, or the field has another value. The time of the entry
+// is not enough, the apiserver updates it whenever the manager writes any of its fields.
+func rewritten(oldConflict, newConflict FieldConflict, changed func(FieldConflict) bool) bool {
+	if newConflict.Overwritten && !oldConflict.Overwritten {
+		return true
+	}
+	return changed != nil && changed(newConflict)
+}
+
+// Helper function returning the matcher of the paths of the original manager entry:
+// regexes matching the paths on any list item, or the structured-merge-diff set
+func newMatcher(originalEntry metav1.ManagedFieldsEntry, options *detectOptions) (func([]FieldPath) bool, error) {
+
+	if options.fieldSets {
+		if err := checkFieldsType(originalEntry); err != nil {
+			return nil, err
+		}
+		return setMatcher(originalEntry, options.cache)
+	}
+
+	originalPaths, err := parseEntryFields(originalEntry, options.cache)
+	if err != nil {
+		return nil, err
+	}
+
+	matchFields := make([]*regexp.Regexp, 0, len(originalPaths))
+	for _, path := range originalPaths {
+		re, err := regexp.Compile(jsonPathToRegex(path.String()))
+		if err != nil {
+			// e.g. field names with regex characters, matched as they are
+			re = regexp.MustCompile(regexp.QuoteMeta(path.String()))
+		}
+		matchFields = append(matchFields, re)
+	}
+
+	return func(paths []FieldPath) bool {
+		return matchesAnyPath(matchFields, paths)
+	}, nil
+}
+
+// Helper function matching the fields of the original manager entry, written at mfTime,
+// against the fields of the other managers
+func detectConflicts(originalEntry metav1.ManagedFieldsEntry, mfTime metav1.Time, matches func([]FieldPath) bool, managedFields []metav1.ManagedFieldsEntry, options *detectOptions) ([]FieldConflict, error) {
+
+	conflicts := []FieldConflict{}
+	originalManager := originalEntry.Manager
+
+	errs := []error{}
+
+	// managedFields: sorting by time
+	for _, idx := range sortManagedFieldsByTime(managedFields) {
+		managedField := managedFields[idx]
+		if managedField.FieldsV1 == nil {
+			continue
+		}
+		// we want only updates, not creation, unless asked for
+		if managedField.Operation == "Create" && !options.create {
+			continue
+		}
+		// we ignore the original manager
+		if managedField.Manager == originalManager {
+			continue
+		}
+
+		externalPaths, err := parseEntryFields(managedField, options.cache)
+		if err != nil {
+			errs = append(errs, newEntryError(idx, managedField, err))
+			continue
+		}
+
+		// entries without time are the oldest, they never overwrite
+		overwritten := managedField.Time != nil && managedField.Time.After(mfTime.Time)
+
+		// regex match the external manager managed fields,
+		// converted to the API version of the original manager
+		for _, path := range externalPaths {
+			if options.ignore.Ignores(managedField, path) {
+				continue
+			}
+			converted := options.conversions.Convert(path, managedField.APIVersion, originalEntry.APIVersion)
+			if !matches(converted) {
+				continue
+			}
+			conflicts = append(conflicts, FieldConflict{
+				OriginalManager: originalManager,
+				ExternalManager: managedField.Manager,
+				Category:        options.classifier.Classify(managedField.Manager),
+				Kind:            NewConflictKind(originalEntry.Operation, managedField.Operation),
+				Path:            path,
+				Operation:       managedField.Operation,
+				Subresource:     managedField.Subresource,
+				APIVersion:      managedField.APIVersion,
+				Time:            managedField.Time,
+				Overwritten:     overwritten,
+			})
+		}
+	}
+
+	return conflicts, errors.Join(errs...)
+}
+
+func matchesAnyPath(regexes []*regexp.Regexp, paths []FieldPath) bool {
+	for _, path := range paths {
+		for _, re := range regexes {
+			if re.MatchString(path.String()) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDetectFieldConflicts(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		managedFields   []metav1.ManagedFieldsEntry
+		originalManager string
+		expectedPaths   []string
+		overwritten     []bool
+	}{
+		{
+			desc:            "no managed fields",
+			managedFields:   []metav1.ManagedFieldsEntry{},
+			originalManager: "original-manager",
+			expectedPaths:   []string{},
+			overwritten:     []bool{},
+		},
+		{
+			desc: "external manager overwriting requests after original-manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+				},
+			},
+			originalManager: "original-manager",
+			expectedPaths:   []string{"spec.template.spec.containers[nginx].resources.requests"},
+			overwritten:     []bool{true},
+		},
+		{
+			desc: "external manager writing limits before original-manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   AppsV1ManagedFieldsMetaAndSpecLimits(),
+					Manager:    "kubectl-client-side-apply",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+					Manager:    "original-manager",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+				},
+			},
+			originalManager: "original-manager",
+			expectedPaths:   []string{"spec.template.spec.containers[nginx].resources.limits"},
+			overwritten:     []bool{false},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			managedFields := append([]metav1.ManagedFieldsEntry{}, tc.managedFields...)
+			conflicts := DetectFieldConflicts(tc.originalManager, managedFields)
+			paths := []string{}
+			overwritten := []bool{}
+			for _, conflict := range conflicts {
+				assert.Equal(t, tc.originalManager, conflict.OriginalManager)
+				paths = append(paths, conflict.Path.Human())
+				overwritten = append(overwritten, conflict.Overwritten)
+			}
+			assert.Equal(t, tc.expectedPaths, paths)
+			assert.Equal(t, tc.overwritten, overwritten)
+			// the managed fields may be shared, e.g. by an informer cache, they are not sorted in place
+			assert.Equal(t, tc.managedFields, managedFields)
+		})
+	}
+}
+
+func TestParseFieldsV1(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		managedFieldsV1 *metav1.FieldsV1
+		expectedHuman   []string
+	}{
+		{
+			desc:            "meta with one annotation",
+			managedFieldsV1: ManagedFieldsMetaSmall(),
+			expectedHuman: []string{
+				"metadata.annotations.nm.kubernetes/utan",
+			},
+		},
+		{
+			desc:            "appsv1 with annotation and container key",
+			managedFieldsV1: AppsV1ManagedFieldsMetaAndSpecWithContainerArgument(),
+			expectedHuman: []string{
+				"metadata.annotations.kubernetes.io/change-cause",
+				"metadata.annotations.stormforge.io/last-updated",
+				"metadata.annotations.stormforge.io/recommendation-url",
+				"spec.template.spec.containers[nginx].args",
+				"spec.template.spec.containers[nginx].command",
+			},
+		},
+		{
+			desc: "set values and multi field keys",
+			managedFieldsV1: &metav1.FieldsV1{Raw: []byte(`
+			{
+				"f:metadata": {"f:finalizers": {"v:\"example.com/protect\"": {}}},
+				"f:spec": {"f:ports": {"k:{\"port\":80,\"protocol\":\"TCP\"}": {".": {}}}}
+			}`)},
+			expectedHuman: []string{
+				"metadata.finalizers[example.com/protect]",
+				"spec.ports[port=80,protocol=TCP]",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			paths, err := ParseFieldsV1(tc.managedFieldsV1)
+			assert.NoError(t, err)
+
+			// the JSON paths must match the ones of FieldsV1ToJSONPaths
+			jsonPaths, err := FieldsV1ToJSONPaths(tc.managedFieldsV1)
+			assert.NoError(t, err)
+
+			human := []string{}
+			asJSONPaths := []string{}
+			for _, path := range paths {
+				human = append(human, path.Human())
+				asJSONPaths = append(asJSONPaths, path.String())
+			}
+			assert.Equal(t, tc.expectedHuman, human)
+			assert.Equal(t, jsonPaths, asJSONPaths)
+		})
+	}
+}
+
+func TestDetectFieldTakeovers(t *testing.T) {
+	original := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+		Manager:    "original-manager",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+	}
+	limits := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   AppsV1ManagedFieldsMetaAndSpecLimits(),
+		Manager:    "kubectl-client-side-apply",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+	}
+	requests := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+		Manager:    "kubectl-client-side-apply",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+	}
+	coOwnedRequests := *requests.DeepCopy()
+	coOwnedRequests.Time = &metav1.Time{Time: MustParseTime("2024-06-16T19:56:27Z")}
+	limitsAgain := *limits.DeepCopy()
+	limitsAgain.Time = &metav1.Time{Time: MustParseTime("2024-06-19T19:56:27Z")}
+
+	testCases := []struct {
+		desc             string
+		oldManagedFields []metav1.ManagedFieldsEntry
+		newManagedFields []metav1.ManagedFieldsEntry
+		expectedPaths    []string
+	}{
+		{
+			desc:             "no original manager in the old version",
+			oldManagedFields: []metav1.ManagedFieldsEntry{limits},
+			newManagedFields: []metav1.ManagedFieldsEntry{requests},
+			expectedPaths:    []string{},
+		},
+		{
+			desc:             "external manager taking over requests",
+			oldManagedFields: []metav1.ManagedFieldsEntry{original},
+			newManagedFields: []metav1.ManagedFieldsEntry{requests},
+			expectedPaths:    []string{"spec.template.spec.containers[nginx].resources.requests"},
+		},
+		{
+			desc:             "external manager already owning limits",
+			oldManagedFields: []metav1.ManagedFieldsEntry{original, limits},
+			newManagedFields: []metav1.ManagedFieldsEntry{original, limits},
+			expectedPaths:    []string{},
+		},
+		{
+			// e.g. kubectl edit co-owning requests, the original manager applying again,
+			// then kubectl edit writing the requests again
+			desc:             "external manager writing requests again",
+			oldManagedFields: []metav1.ManagedFieldsEntry{original, coOwnedRequests},
+			newManagedFields: []metav1.ManagedFieldsEntry{original, requests},
+			expectedPaths:    []string{"spec.template.spec.containers[nginx].resources.requests"},
+		},
+		{
+			// the time of the entry changes whenever the manager writes any field, e.g. an annotation
+			desc:             "external manager co-owning limits writing its entry again",
+			oldManagedFields: []metav1.ManagedFieldsEntry{original, limits},
+			newManagedFields: []metav1.ManagedFieldsEntry{original, limitsAgain},
+			expectedPaths:    []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			paths := []string{}
+			for _, takeover := range DetectFieldTakeovers("original-manager", tc.oldManagedFields, tc.newManagedFields) {
+				paths = append(paths, takeover.Path.Human())
+			}
+			assert.Equal(t, tc.expectedPaths, paths)
+		})
+	}
+}
+
+func TestDetectFieldConflictsOperations(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		managedFields []metav1.ManagedFieldsEntry
+		options       []DetectOption
+		expected      []string
+	}{
+		{
+			desc: "update stole apply",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Apply", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			expected: []string{"kubectl-edit update-stole-apply"},
+		},
+		{
+			desc: "apply vs apply",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Apply", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("argocd-controller", "Apply", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			expected: []string{"argocd-controller apply-vs-apply"},
+		},
+		{
+			desc: "apply vs update",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("argocd-controller", "Apply", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			expected: []string{"argocd-controller apply-vs-update"},
+		},
+		{
+			desc: "create skipped",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Apply", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-create", "Create", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-16T19:56:27Z"),
+			},
+			expected: []string{},
+		},
+		{
+			desc: "create included",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Apply", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-create", "Create", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-16T19:56:27Z"),
+			},
+			options:  []DetectOption{WithCreate()},
+			expected: []string{"kubectl-create update-stole-apply"},
+		},
+		{
+			desc: "create of the original manager included",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Create", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			options:  []DetectOption{WithCreate()},
+			expected: []string{"kubectl-edit update-vs-update"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			actual := []string{}
+			for _, conflict := range DetectFieldConflicts("original-manager", tc.managedFields, tc.options...) {
+				actual = append(actual, fmt.Sprintf("%s %s", conflict.ExternalManager, conflict.Kind))
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+)
+
+// PathConverter converts a field path of an API version into the paths of the same field
+// in another API version, no paths when the field does not exist in the other version
+type PathConverter func(path FieldPath) []FieldPath
+
+// ConversionRegistry holds the path converters between API versions,
+// so the managed fields written with different versions can be compared
+type ConversionRegistry struct {
+	mu         sync.RWMutex
+	converters map[conversionKey]PathConverter
+}
+
+type conversionKey struct {
+	from string
+	to   string
+}
+
+// NewConversionRegistry returns an empty registry
+func NewConversionRegistry() *ConversionRegistry {
+	return &ConversionRegistry{converters: map[conversionKey]PathConverter{}}
+}
+
+var (
+	defaultConversionRegistry     *ConversionRegistry
+	defaultConversionRegistryOnce sync.Once
+)
+
+// DefaultConversionRegistry returns the registry used by the detection functions,
+// with the built-in conversions:
+//   - autoscaling/v1 and autoscaling/v2 HorizontalPodAutoscalers, targetCPUUtilizationPercentage
+//     and currentCPUUtilizationPercentage are metrics and currentMetrics in v2
+//   - autoscaling/v2beta1 and autoscaling/v2beta2 into autoscaling/v2, same fields
+//   - extensions/v1beta1, apps/v1beta1 and apps/v1beta2 into apps/v1,
+//     without the rollbackTo and templateGeneration fields removed in apps/v1
+func DefaultConversionRegistry() *ConversionRegistry {
+	defaultConversionRegistryOnce.Do(func() {
+		defaultConversionRegistry = NewConversionRegistry()
+		registerBuiltInConversions(defaultConversionRegistry)
+	})
+	return defaultConversionRegistry
+}
+
+// Register adds the converter of the paths from an API version to another one, replacing any previous one
+func (r *ConversionRegistry) Register(fromAPIVersion, toAPIVersion string, converter PathConverter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.converters[conversionKey{from: fromAPIVersion, to: toAPIVersion}] = converter
+}
+
+// Convert returns the paths of the field in the other API version. Paths are returned
+// unchanged when the versions are the same, or when no converter is registered for them.
+func (r *ConversionRegistry) Convert(path FieldPath, fromAPIVersion, toAPIVersion string) []FieldPath {
+	if r == nil || fromAPIVersion == toAPIVersion {
+		return []FieldPath{path}
+	}
+
+	r.mu.RLock()
+	converter, found := r.converters[conversionKey{from: fromAPIVersion, to: toAPIVersion}]
+	r.mu.RUnlock()
+
+	if !found {
+		return []FieldPath{path}
+	}
+
+	return converter(path)
+}
+
+// RenameConverter returns a converter replacing the path prefixes, given as dotted field names
+// (e.g. spec.targetCPUUtilizationPercentage), by their new names. An empty new name removes the fields.
+// The longest prefix matching a path wins, e.g. spec.a.b before spec.a.
+func RenameConverter(renames map[string]string) PathConverter {
+
+	type rename struct {
+		from FieldPath
+		to   string
+	}
+
+	sorted := make([]rename, 0, len(renames))
+	for from, to := range renames {
+		sorted = append(sorted, rename{from: dottedFieldPath(from), to: to})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].from) != len(sorted[j].from) {
+			return len(sorted[i].from) > len(sorted[j].from)
+		}
+		return sorted[i].from.String() < sorted[j].from.String()
+	})
+
+	return func(path FieldPath) []FieldPath {
+		for _, r := range sorted {
+			if !hasPrefix(path, r.from) {
+				continue
+			}
+			if r.to == "" {
+				return []FieldPath{}
+			}
+			converted := append(dottedFieldPath(r.to), path[len(r.from):]...)
+			return []FieldPath{converted}
+		}
+		return []FieldPath{path}
+	}
+}
+
+func registerBuiltInConversions(r *ConversionRegistry) {
+
+	// in autoscaling/v2 the metrics are lists, atomic as a whole
+	r.Register("autoscaling/v1", "autoscaling/v2", RenameConverter(map[string]string{
+		"spec.targetCPUUtilizationPercentage":    "spec.metrics",
+		"status.currentCPUUtilizationPercentage": "status.currentMetrics",
+	}))
+	r.Register("autoscaling/v2", "autoscaling/v1", RenameConverter(map[string]string{
+		"spec.metrics":          "spec.targetCPUUtilizationPercentage",
+		"status.currentMetrics": "status.currentCPUUtilizationPercentage",
+		"spec.behavior":         "",
+	}))
+
+	for _, beta := range []string{"autoscaling/v2beta1", "autoscaling/v2beta2"} {
+		r.Register(beta, "autoscaling/v2", RenameConverter(nil))
+		r.Register("autoscaling/v2", beta, RenameConverter(nil))
+	}
+
+	for _, beta := range []string{"extensions/v1beta1", "apps/v1beta1", "apps/v1beta2"} {
+		r.Register(beta, "apps/v1", RenameConverter(map[string]string{
+			"spec.rollbackTo":         "",
+			"spec.templateGeneration": "",
+		}))
+		r.Register("apps/v1", beta, RenameConverter(nil))
+	}
+}
+
+// Helper function returning the field path of dotted field names
+func dottedFieldPath(dotted string) FieldPath {
+	path := FieldPath{}
+	start := 0
+	for i := 0; i <= len(dotted); i++ {
+		if i == len(dotted) || dotted[i] == '.' {
+			path = append(path, PathElement{Kind: FieldElement, Value: dotted[start:i]})
+			start = i + 1
+		}
+	}
+	return path
+}
+
+func hasPrefix(path, prefix FieldPath) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
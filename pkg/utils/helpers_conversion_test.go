@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConversionRegistryConvert(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		path           string
+		fromAPIVersion string
+		toAPIVersion   string
+		expectedPaths  []string
+	}{
+		{
+			desc:           "same version",
+			path:           "spec.targetCPUUtilizationPercentage",
+			fromAPIVersion: "autoscaling/v1",
+			toAPIVersion:   "autoscaling/v1",
+			expectedPaths:  []string{"spec.targetCPUUtilizationPercentage"},
+		},
+		{
+			desc:           "hpa v1 target cpu into v2 metrics",
+			path:           "spec.targetCPUUtilizationPercentage",
+			fromAPIVersion: "autoscaling/v1",
+			toAPIVersion:   "autoscaling/v2",
+			expectedPaths:  []string{"spec.metrics"},
+		},
+		{
+			desc:           "hpa v2 metrics into v1 target cpu",
+			path:           "spec.metrics",
+			fromAPIVersion: "autoscaling/v2",
+			toAPIVersion:   "autoscaling/v1",
+			expectedPaths:  []string{"spec.targetCPUUtilizationPercentage"},
+		},
+		{
+			desc:           "hpa v2 behavior does not exist in v1",
+			path:           "spec.behavior.scaleDown",
+			fromAPIVersion: "autoscaling/v2",
+			toAPIVersion:   "autoscaling/v1",
+			expectedPaths:  []string{},
+		},
+		{
+			desc:           "unchanged hpa field",
+			path:           "spec.maxReplicas",
+			fromAPIVersion: "autoscaling/v1",
+			toAPIVersion:   "autoscaling/v2",
+			expectedPaths:  []string{"spec.maxReplicas"},
+		},
+		{
+			desc:           "extensions rollbackTo does not exist in apps/v1",
+			path:           "spec.rollbackTo.revision",
+			fromAPIVersion: "extensions/v1beta1",
+			toAPIVersion:   "apps/v1",
+			expectedPaths:  []string{},
+		},
+		{
+			desc:           "no conversion registered",
+			path:           "spec.replicas",
+			fromAPIVersion: "example.com/v1",
+			toAPIVersion:   "example.com/v2",
+			expectedPaths:  []string{"spec.replicas"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			converted := DefaultConversionRegistry().Convert(dottedFieldPath(tc.path), tc.fromAPIVersion, tc.toAPIVersion)
+			assert.Equal(t, tc.expectedPaths, humanPaths(converted))
+		})
+	}
+}
+
+func TestRenameConverterOverlappingPrefixes(t *testing.T) {
+	renames := map[string]string{
+		"spec.a":   "spec.x",
+		"spec.a.b": "spec.y",
+		"spec.c":   "",
+		"spec.c.d": "spec.z",
+	}
+
+	testCases := []struct {
+		desc          string
+		path          string
+		expectedPaths []string
+	}{
+		{desc: "longest prefix", path: "spec.a.b.c", expectedPaths: []string{"spec.y.c"}},
+		{desc: "shorter prefix", path: "spec.a.e", expectedPaths: []string{"spec.x.e"}},
+		{desc: "longest prefix renamed, shorter removed", path: "spec.c.d", expectedPaths: []string{"spec.z"}},
+		{desc: "shorter prefix removed", path: "spec.c.e", expectedPaths: []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			// the iteration order of the renames must not matter
+			for i := 0; i < 20; i++ {
+				assert.Equal(t, tc.expectedPaths, humanPaths(RenameConverter(renames)(dottedFieldPath(tc.path))))
+			}
+		})
+	}
+}
+
+func TestDetectExternalManagerAcrossAPIVersions(t *testing.T) {
+	managedFields := func() []metav1.ManagedFieldsEntry {
+		return []metav1.ManagedFieldsEntry{
+			{
+				APIVersion: "autoscaling/v1",
+				FieldsType: "FieldsV1",
+				FieldsV1:   HPAManagedFieldsMetaAndSpec(),
+				Manager:    "kubectl-client-side-apply",
+				Operation:  "Update",
+				Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+			},
+			{
+				APIVersion: "autoscaling/v2",
+				FieldsType: "FieldsV1",
+				FieldsV1:   HPAV2ManagedFieldsSpecMetricsAndMaxReplicas(),
+				Manager:    "original-manager",
+				Operation:  "Update",
+				Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+			},
+		}
+	}
+
+	// v1 targetCPUUtilizationPercentage is v2 metrics
+	wasOverwritten, manager := DetectExternalManager("original-manager", managedFields())
+	assert.True(t, wasOverwritten)
+	assert.Equal(t, "kubectl-client-side-apply", manager)
+
+	conflicts := DetectFieldConflicts("original-manager", managedFields())
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "spec.targetCPUUtilizationPercentage", conflicts[0].Path.Human())
+
+	// without conversions the paths never overlap
+	wasOverwritten, manager = DetectExternalManager("original-manager", managedFields(), WithConversions(nil))
+	assert.False(t, wasOverwritten)
+	assert.Equal(t, "", manager)
+
+	// custom conversions
+	registry := NewConversionRegistry()
+	registry.Register("autoscaling/v1", "autoscaling/v2", RenameConverter(map[string]string{
+		"spec.minReplicas": "spec.maxReplicas",
+	}))
+	conflicts = DetectFieldConflicts("original-manager", managedFields(), WithConversions(registry))
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "spec.minReplicas", conflicts[0].Path.Human())
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ManagedFieldsDiff is the difference between two snapshots of the managed fields of an object,
+// e.g. the object of yesterday's backup and today's
+type ManagedFieldsDiff struct {
+	// Managers are the fields of every manager, all its entries together, sorted by manager
+	Managers []ManagerFieldsDiff
+	// Added and Removed are the entries only in the after and before snapshots,
+	// entries are identified by ManagerIdentifier like the apiserver does
+	Added   []metav1.ManagedFieldsEntry
+	Removed []metav1.ManagedFieldsEntry
+	// TimeChanges are the entries in both snapshots with a different time
+	TimeChanges []TimeChange
+}
+
+// ManagerFieldsDiff is the difference of the fields of a manager
+type ManagerFieldsDiff struct {
+	Manager   string
+	Gained    []FieldPath
+	Lost      []FieldPath
+	Unchanged []FieldPath
+}
+
+// TimeChange is an entry written again between the snapshots
+type TimeChange struct {
+	Manager     string
+	Operation   metav1.ManagedFieldsOperationType
+	Subresource string
+	// APIVersion is the API version of the entry, the entries of Update operations
+	// in several API versions are different entries
+	APIVersion string
+	Before     *metav1.Time
+	After      *metav1.Time
+}
+
+// DiffManagedFields compares two snapshots of the managed fields of an object
+// and reports what changed hands. Of the options, WithParseCache applies,
+// e.g. to diff the successive versions of the objects of a watcher.
+func DiffManagedFields(before, after []metav1.ManagedFieldsEntry, opts ...DetectOption) (ManagedFieldsDiff, error) {
+
+	options := newDetectOptions(opts)
+
+	diff := ManagedFieldsDiff{
+		Managers:    []ManagerFieldsDiff{},
+		Added:       []metav1.ManagedFieldsEntry{},
+		Removed:     []metav1.ManagedFieldsEntry{},
+		TimeChanges: []TimeChange{},
+	}
+
+	beforeFields, err := fieldsByManager(before, options.cache)
+	if err != nil {
+		return diff, fmt.Errorf("before: %w", err)
+	}
+	afterFields, err := fieldsByManager(after, options.cache)
+	if err != nil {
+		return diff, fmt.Errorf("after: %w", err)
+	}
+
+	managers := []string{}
+	for manager := range beforeFields {
+		managers = append(managers, manager)
+	}
+	for manager := range afterFields {
+		if _, found := beforeFields[manager]; !found {
+			managers = append(managers, manager)
+		}
+	}
+	sort.Strings(managers)
+
+	for _, manager := range managers {
+		beforeSet := beforeFields[manager]
+		afterSet := afterFields[manager]
+		diff.Managers = append(diff.Managers, ManagerFieldsDiff{
+			Manager:   manager,
+			Gained:    afterSet.Difference(beforeSet).List(),
+			Lost:      beforeSet.Difference(afterSet).List(),
+			Unchanged: beforeSet.Intersection(afterSet).List(),
+		})
+	}
+
+	beforeIdentifiers, err := entryIdentifiers(before)
+	if err != nil {
+		return diff, fmt.Errorf("before: %w", err)
+	}
+	afterIdentifiers, err := entryIdentifiers(after)
+	if err != nil {
+		return diff, fmt.Errorf("after: %w", err)
+	}
+
+	beforeEntries := map[string]metav1.ManagedFieldsEntry{}
+	for idx, entry := range before {
+		beforeEntries[beforeIdentifiers[idx]] = entry
+	}
+	afterEntries := map[string]metav1.ManagedFieldsEntry{}
+	for idx, entry := range after {
+		afterEntries[afterIdentifiers[idx]] = entry
+	}
+
+	for idx, entry := range after {
+		previous, found := beforeEntries[afterIdentifiers[idx]]
+		if !found {
+			diff.Added = append(diff.Added, entry)
+			continue
+		}
+		if !timesEqual(previous.Time, entry.Time) {
+			diff.TimeChanges = append(diff.TimeChanges, TimeChange{
+				Manager:     entry.Manager,
+				Operation:   entry.Operation,
+				Subresource: entry.Subresource,
+				APIVersion:  entry.APIVersion,
+				Before:      previous.Time,
+				After:       entry.Time,
+			})
+		}
+	}
+
+	for idx, entry := range before {
+		if _, found := afterEntries[beforeIdentifiers[idx]]; !found {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+
+	return diff, nil
+}
+
+// Helper function returning the fields of every manager, all its entries together,
+// parsed with the cache when not nil, the entries of unsupported fields types are errors like in the detection
+func fieldsByManager(managedFields []metav1.ManagedFieldsEntry, cache *ParseCache) (map[string]FieldPathSet, error) {
+	fields := map[string]FieldPathSet{}
+	for idx, managedField := range managedFields {
+		if managedField.FieldsV1 == nil {
+			continue
+		}
+		paths, err := parseEntryFields(managedField, cache)
+		if err != nil {
+			return nil, newEntryError(idx, managedField, err)
+		}
+		fields[managedField.Manager] = NewFieldPathSet(paths...).Union(fields[managedField.Manager])
+	}
+	return fields, nil
+}
+
+// Helper function returning the ManagerIdentifier of every entry, the apiserver keeps one entry
+// per manager, operation and subresource, and API version for Update operations
+func entryIdentifiers(managedFields []metav1.ManagedFieldsEntry) ([]string, error) {
+	identifiers := make([]string, 0, len(managedFields))
+	for idx, managedField := range managedFields {
+		identifier, err := ManagerIdentifier(managedField)
+		if err != nil {
+			return nil, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err)
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	return identifiers, nil
+}
+
+func timesEqual(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b)
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func humanPaths(paths []FieldPath) []string {
+	result := []string{}
+	for _, path := range paths {
+		result = append(result, path.Human())
+	}
+	return result
+}
+
+func TestDiffManagedFields(t *testing.T) {
+	before := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "original-manager",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "autoscaling/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   ManagedFieldsMetaSmall(),
+			Manager:    "helm",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-16T19:56:27Z")},
+		},
+	}
+	after := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecLimits(),
+			Manager:    "original-manager",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecWithContainerArgument(),
+			Manager:    "original-manager",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-19T19:56:27Z")},
+		},
+		{
+			APIVersion: "autoscaling/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   ManagedFieldsMetaSmall(),
+			Manager:    "helm",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-19T20:00:00Z")},
+		},
+	}
+
+	diff, err := DiffManagedFields(before, after)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Managers, 3)
+
+	assert.Equal(t, "helm", diff.Managers[0].Manager)
+	assert.Empty(t, diff.Managers[0].Gained)
+	assert.Empty(t, diff.Managers[0].Lost)
+	assert.Equal(t, []string{"metadata.annotations.nm.kubernetes/utan"}, humanPaths(diff.Managers[0].Unchanged))
+
+	assert.Equal(t, "kubectl-client-side-apply", diff.Managers[1].Manager)
+	assert.Equal(t, []string{"spec.template.spec.containers[nginx].resources.requests"}, humanPaths(diff.Managers[1].Gained))
+	assert.Empty(t, diff.Managers[1].Lost)
+
+	assert.Equal(t, "original-manager", diff.Managers[2].Manager)
+	assert.Empty(t, diff.Managers[2].Gained)
+	assert.Equal(t, []string{"spec.template.spec.containers[nginx].resources.requests"}, humanPaths(diff.Managers[2].Lost))
+	assert.Len(t, diff.Managers[2].Unchanged, 6)
+
+	require.Len(t, diff.Added, 2)
+	assert.Equal(t, "original-manager", diff.Added[0].Manager)
+	assert.Equal(t, metav1.ManagedFieldsOperationApply, diff.Added[0].Operation)
+	assert.Equal(t, "kubectl-client-side-apply", diff.Added[1].Manager)
+	assert.Empty(t, diff.Removed)
+
+	require.Len(t, diff.TimeChanges, 1)
+	assert.Equal(t, "helm", diff.TimeChanges[0].Manager)
+	assert.Equal(t, MustParseTime("2024-06-16T19:56:27Z"), diff.TimeChanges[0].Before.Time)
+	assert.Equal(t, MustParseTime("2024-06-19T20:00:00Z"), diff.TimeChanges[0].After.Time)
+
+	// and the other way around
+	diff, err = DiffManagedFields(after, before)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Len(t, diff.Removed, 2)
+}
+
+func TestDiffManagedFieldsAPIVersions(t *testing.T) {
+	v1 := metav1.ManagedFieldsEntry{
+		APIVersion:  "autoscaling/v1",
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:currentReplicas":{}}}`)},
+		Manager:     "kube-controller-manager",
+		Operation:   "Update",
+		Subresource: "status",
+		Time:        &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+	}
+	v2 := *v1.DeepCopy()
+	v2.APIVersion = "autoscaling/v2"
+	v2.FieldsV1 = &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:conditions":{}}}`)}
+	v2.Time = &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")}
+	v2Again := *v2.DeepCopy()
+	v2Again.Time = &metav1.Time{Time: MustParseTime("2024-06-19T19:56:27Z")}
+
+	// the entries of Update operations in another API version are other entries
+	diff, err := DiffManagedFields([]metav1.ManagedFieldsEntry{v1}, []metav1.ManagedFieldsEntry{v1, v2})
+	require.NoError(t, err)
+	assert.Equal(t, []metav1.ManagedFieldsEntry{v2}, diff.Added)
+	assert.Empty(t, diff.Removed)
+	assert.Empty(t, diff.TimeChanges)
+
+	diff, err = DiffManagedFields([]metav1.ManagedFieldsEntry{v1, v2}, []metav1.ManagedFieldsEntry{v2Again})
+	require.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Equal(t, []metav1.ManagedFieldsEntry{v1}, diff.Removed)
+	assert.Equal(t, []TimeChange{{
+		Manager:     "kube-controller-manager",
+		Operation:   "Update",
+		Subresource: "status",
+		APIVersion:  "autoscaling/v2",
+		Before:      v2.Time,
+		After:       v2Again.Time,
+	}}, diff.TimeChanges)
+}
+
+func TestDiffManagedFieldsUnsupportedFieldsType(t *testing.T) {
+	before := []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+	}
+	unsupported := managedFieldsEntry("helm", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z")
+	unsupported.FieldsType = "FieldsV2"
+	after := append(before, unsupported)
+
+	// reported like the detection functions do, not decoded as FieldsV1
+	_, err := DiffManagedFields(before, after)
+	assert.EqualError(t, err, `after: managedFields[1] of helm: unsupported fieldsType "FieldsV2"`)
+	entryErrors := EntryErrors(err)
+	require.Len(t, entryErrors, 1)
+	assert.Equal(t, 1, entryErrors[0].Index)
+}
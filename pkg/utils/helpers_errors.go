@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EntryError is a managed fields entry that could not be analyzed
+type EntryError struct {
+	// Index is the index of the entry in the managed fields
+	Index   int
+	Manager string
+	Err     error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("managedFields[%d] of %s: %v", e.Index, e.Manager, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// EntryErrors returns the entry errors of an error of the detection functions, joined or not
+func EntryErrors(err error) []*EntryError {
+
+	entryErrors := []*EntryError{}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			entryErrors = append(entryErrors, EntryErrors(e)...)
+		}
+		return entryErrors
+	}
+
+	var entryError *EntryError
+	if errors.As(err, &entryError) {
+		entryErrors = append(entryErrors, entryError)
+	}
+
+	return entryErrors
+}
+
+func newEntryError(idx int, managedField metav1.ManagedFieldsEntry, err error) *EntryError {
+	return &EntryError{Index: idx, Manager: managedField.Manager, Err: err}
+}
+
+// Helper function parsing the fields of an entry, with a supported fields type,
+// with the cache when not nil
+func parseEntryFields(managedField metav1.ManagedFieldsEntry, cache *ParseCache) ([]FieldPath, error) {
+	if err := checkFieldsType(managedField); err != nil {
+		return nil, err
+	}
+	return cache.ParseFieldsV1(managedField.FieldsV1)
+}
+
+func checkFieldsType(managedField metav1.ManagedFieldsEntry) error {
+	if managedField.FieldsType != "" && managedField.FieldsType != "FieldsV1" {
+		return fmt.Errorf("unsupported fieldsType %q", managedField.FieldsType)
+	}
+	return nil
+}
+
+// Helper function prefixing every joined error, e.g. with the version of the managed fields
+func prefixErrors(prefix string, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := []error{}
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, prefixErrors(prefix, e))
+		}
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("%s %w", prefix, err)
+}
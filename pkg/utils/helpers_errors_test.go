@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDetectFieldConflictsWithErrors(t *testing.T) {
+	broken := &metav1.FieldsV1{Raw: []byte(`{"f:spec":`)}
+	unsupported := managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z")
+	unsupported.FieldsType = "FieldsV2"
+
+	testCases := []struct {
+		desc              string
+		managedFields     []metav1.ManagedFieldsEntry
+		options           []DetectOption
+		expectedConflicts []string
+		expectedErrors    []string
+	}{
+		{
+			desc: "clean object",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			expectedConflicts: []string{"kubectl-edit spec.template.spec.containers[nginx].resources.requests"},
+			expectedErrors:    []string{},
+		},
+		{
+			desc: "broken external entry",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("helm", "Update", broken, "2024-06-19T19:56:27Z"),
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+			},
+			expectedConflicts: []string{"kubectl-edit spec.template.spec.containers[nginx].resources.requests"},
+			expectedErrors:    []string{"1 helm"},
+		},
+		{
+			desc: "unsupported fields type",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				unsupported,
+				managedFieldsEntry("helm", "Update", broken, "2024-06-19T19:56:27Z"),
+			},
+			expectedConflicts: []string{},
+			expectedErrors:    []string{"1 kubectl-edit", "2 helm"},
+		},
+		{
+			desc: "broken original entry",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+				managedFieldsEntry("original-manager", "Update", broken, "2024-06-17T19:56:27Z"),
+			},
+			expectedConflicts: []string{},
+			expectedErrors:    []string{"1 original-manager"},
+		},
+		{
+			desc: "broken original entry with field sets",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kubectl-edit", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-18T19:56:27Z"),
+				managedFieldsEntry("original-manager", "Update", broken, "2024-06-17T19:56:27Z"),
+			},
+			options:           []DetectOption{WithFieldSets()},
+			expectedConflicts: []string{},
+			expectedErrors:    []string{"1 original-manager"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			conflicts, err := DetectFieldConflictsWithErrors("original-manager", tc.managedFields, tc.options...)
+
+			actualConflicts := []string{}
+			for _, conflict := range conflicts {
+				actualConflicts = append(actualConflicts, fmt.Sprintf("%s %s", conflict.ExternalManager, conflict.Path.Human()))
+			}
+			assert.Equal(t, tc.expectedConflicts, actualConflicts)
+
+			actualErrors := []string{}
+			for _, entryError := range EntryErrors(err) {
+				assert.ErrorContains(t, err, entryError.Error())
+				actualErrors = append(actualErrors, fmt.Sprintf("%d %s", entryError.Index, entryError.Manager))
+			}
+			assert.Equal(t, tc.expectedErrors, actualErrors)
+			if len(tc.expectedErrors) == 0 {
+				assert.NoError(t, err)
+			}
+
+			// the variants without errors return the same conflicts
+			assert.Equal(t, conflicts, DetectFieldConflicts("original-manager", tc.managedFields, tc.options...))
+
+			_, externalManager, externalErr := DetectExternalManagerWithErrors("original-manager", tc.managedFields, tc.options...)
+			assert.Equal(t, err, externalErr)
+			if len(conflicts) > 0 {
+				assert.Equal(t, conflicts[len(conflicts)-1].ExternalManager, externalManager)
+			}
+		})
+	}
+}
+
+func TestDetectFieldTakeoversWithErrors(t *testing.T) {
+	oldManagedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "original-manager",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+	}
+	newManagedFields := append(oldManagedFields,
+		metav1.ManagedFieldsEntry{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":`)},
+			Manager:    "helm",
+			Operation:  "Update",
+		},
+		metav1.ManagedFieldsEntry{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-edit",
+			Operation:  "Update",
+		},
+	)
+
+	takeovers, err := DetectFieldTakeoversWithErrors("original-manager", oldManagedFields, newManagedFields)
+	require.Len(t, takeovers, 1)
+	assert.Equal(t, "kubectl-edit", takeovers[0].ExternalManager)
+	assert.ErrorContains(t, err, "new managedFields[1] of helm: ")
+
+	entryErrors := EntryErrors(err)
+	require.Len(t, entryErrors, 1)
+	assert.Equal(t, 1, entryErrors[0].Index)
+}
+
+func TestEntryErrors(t *testing.T) {
+	assert.Empty(t, EntryErrors(nil))
+	assert.Empty(t, EntryErrors(fmt.Errorf("not an entry error")))
+
+	err := &EntryError{Index: 3, Manager: "helm", Err: fmt.Errorf("unsupported fieldsType \"FieldsV2\"")}
+	assert.EqualError(t, err, `managedFields[3] of helm: unsupported fieldsType "FieldsV2"`)
+	assert.Equal(t, []*EntryError{err}, EntryErrors(fmt.Errorf("new %w", err)))
+}
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PathElementKind is the kind of a FieldsV1 key, given by its prefix
+type PathElementKind string
+
+const (
+	// FieldElement is a "f:" key, a field of a map or struct
+	FieldElement PathElementKind = "f"
+	// KeyElement is a "k:" key, an item of an associative list
+	KeyElement PathElementKind = "k"
+	// ValueElement is a "v:" key, an item of a set
+	ValueElement PathElementKind = "v"
+	// IndexElement is a "i:" key, an item of an atomic list
+	IndexElement PathElementKind = "i"
+	// SelfElement is the "." key, the parent element itself
+	SelfElement PathElementKind = "."
+)
+
+// PathElement is one level of a managed field path
+type PathElement struct {
+	Kind PathElementKind
+	// Value is the FieldsV1 key without its prefix, e.g. the field name
+	// or the JSON encoded key of a list item
+	Value string
+}
+
+// FieldPath is a managed field path, one element per level of the FieldsV1 tree
+type FieldPath []PathElement
+
+// ParsePathElement parses a single FieldsV1 key such as "f:spec" or "k:{\"name\":\"nginx\"}"
+func ParsePathElement(key string) (PathElement, error) {
+	if key == "." {
+		return PathElement{Kind: SelfElement}, nil
+	}
+
+	prefix, value, found := strings.Cut(key, ":")
+	if !found {
+		return PathElement{}, fmt.Errorf("invalid fieldsV1 key %q", key)
+	}
+
+	switch kind := PathElementKind(prefix); kind {
+	case FieldElement, KeyElement, ValueElement, IndexElement:
+		return PathElement{Kind: kind, Value: value}, nil
+	}
+
+	return PathElement{}, fmt.Errorf("invalid fieldsV1 key prefix %q", key)
+}
+
+// ParseFieldsV1 returns the leaf paths of the managed fields FieldsV1,
+// sorted by their JSON path
+func ParseFieldsV1(fieldsV1 *metav1.FieldsV1) ([]FieldPath, error) {
+
+	paths := []FieldPath{}
+
+	if fieldsV1 == nil {
+		return paths, fmt.Errorf("fieldsV1 nil")
+	}
+
+	var fieldsMap map[string]interface{}
+	if err := json.Unmarshal(fieldsV1.Raw, &fieldsMap); err != nil {
+		return paths, err
+	}
+
+	if err := extractFieldPaths(nil, fieldsMap, &paths); err != nil {
+		return paths, err
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].String() < paths[j].String()
+	})
+
+	return paths, nil
+}
+
+// Helper function to recursively extract field paths from the fields map
+func extractFieldPaths(prefix FieldPath, m map[string]interface{}, paths *[]FieldPath) error {
+	for key, val := range m {
+		element, err := ParsePathElement(key)
+		if err != nil {
+			return err
+		}
+
+		// copy the prefix so sibling paths do not share the backing array
+		fullPath := make(FieldPath, len(prefix), len(prefix)+1)
+		copy(fullPath, prefix)
+		fullPath = append(fullPath, element)
+
+		if nested, ok := val.(map[string]interface{}); ok {
+			if len(nested) > 0 {
+				if err := extractFieldPaths(fullPath, nested, paths); err != nil {
+					return err
+				}
+			} else {
+				*paths = append(*paths, fullPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// String returns the path in the JSON path format of FieldsV1ToJSONPaths
+func (p FieldPath) String() string {
+	var sb strings.Builder
+	for _, element := range p {
+		sb.WriteString("/")
+		switch element.Kind {
+		case FieldElement:
+			sb.WriteString(element.Value)
+		case KeyElement:
+			sb.WriteString("[" + element.Value + "]")
+		case SelfElement:
+			sb.WriteString(".")
+		default:
+			sb.WriteString(string(element.Kind) + ":" + element.Value)
+		}
+	}
+	return sb.String()
+}
+
+// Human returns the path in a dotted, human readable format,
+// e.g. spec.template.spec.containers[nginx].resources.requests
+func (p FieldPath) Human() string {
+	var sb strings.Builder
+	for _, element := range p {
+		switch element.Kind {
+		case FieldElement:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(element.Human())
+		case KeyElement, ValueElement, IndexElement:
+			sb.WriteString("[" + element.Human() + "]")
+		}
+	}
+	return sb.String()
+}
+
+// Human returns the element in a human readable format: the field name,
+// the bare value of single field keys, key=value pairs of the other keys,
+// the value of set items or the index of list items
+func (e PathElement) Human() string {
+	switch e.Kind {
+	case KeyElement:
+		return humanKey(e.Value)
+	case ValueElement:
+		return humanValue(e.Value)
+	case SelfElement:
+		return ""
+	}
+	return e.Value
+}
+
+// Parent returns the path without its last element, the empty path has no parent
+func (p FieldPath) Parent() FieldPath {
+	if len(p) == 0 {
+		return p
+	}
+	return p[:len(p)-1]
+}
+
+// Helper function to render a list item key: the bare value when the key
+// has a single field, key=value pairs otherwise
+func humanKey(raw string) string {
+	var key map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &key); err != nil {
+		return raw
+	}
+
+	if len(key) == 1 {
+		for _, v := range key {
+			return humanValue(marshalValue(v))
+		}
+	}
+
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(key))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+humanValue(marshalValue(key[name])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Helper function to unquote JSON strings, other values are returned as is
+func humanValue(raw string) string {
+	var s string
+	if err := json.Unmarshal([]byte(raw), &s); err == nil {
+		return s
+	}
+	return raw
+}
+
+func marshalValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// FieldPathSet is a set of field paths, keyed by their JSON path
+type FieldPathSet map[string]FieldPath
+
+// NewFieldPathSet returns a set with the paths
+func NewFieldPathSet(paths ...FieldPath) FieldPathSet {
+	s := FieldPathSet{}
+	s.Insert(paths...)
+	return s
+}
+
+// FieldsV1ToFieldPathSet returns the set of the leaf paths of the FieldsV1
+func FieldsV1ToFieldPathSet(fieldsV1 *metav1.FieldsV1) (FieldPathSet, error) {
+	paths, err := ParseFieldsV1(fieldsV1)
+	if err != nil {
+		return nil, err
+	}
+	return NewFieldPathSet(paths...), nil
+}
+
+// Insert adds the paths to the set
+func (s FieldPathSet) Insert(paths ...FieldPath) {
+	for _, path := range paths {
+		s[path.String()] = path
+	}
+}
+
+// Has returns true if the path is in the set
+func (s FieldPathSet) Has(path FieldPath) bool {
+	_, found := s[path.String()]
+	return found
+}
+
+// Union returns the paths in either set
+func (s FieldPathSet) Union(other FieldPathSet) FieldPathSet {
+	result := FieldPathSet{}
+	for key, path := range s {
+		result[key] = path
+	}
+	for key, path := range other {
+		result[key] = path
+	}
+	return result
+}
+
+// Intersection returns the paths in both sets
+func (s FieldPathSet) Intersection(other FieldPathSet) FieldPathSet {
+	result := FieldPathSet{}
+	for key, path := range s {
+		if _, found := other[key]; found {
+			result[key] = path
+		}
+	}
+	return result
+}
+
+// Difference returns the paths in the set that are not in the other
+func (s FieldPathSet) Difference(other FieldPathSet) FieldPathSet {
+	result := FieldPathSet{}
+	for key, path := range s {
+		if _, found := other[key]; !found {
+			result[key] = path
+		}
+	}
+	return result
+}
+
+// List returns the paths sorted by their JSON path
+func (s FieldPathSet) List() []FieldPath {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := make([]FieldPath, 0, len(s))
+	for _, key := range keys {
+		paths = append(paths, s[key])
+	}
+	return paths
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// IgnoreRule describes benign writes of external managers, which are not conflicts,
+// e.g. the status written by kube-controller-manager or the labels added with Lens.
+// A write is ignored when it matches every non-empty list of the rule, a rule needs one at least.
+type IgnoreRule struct {
+	// Managers are path.Match patterns of the manager names, matched as written and normalized,
+	// e.g. kube-controller-manager or k8slens-*
+	Managers []string `json:"managers,omitempty"`
+	// Paths are path globs of the fields, see ParsePathPattern, e.g. status or metadata.labels
+	Paths []string `json:"paths,omitempty"`
+	// Subresources of the entries, e.g. status, "" is the main resource
+	Subresources []string `json:"subresources,omitempty"`
+	// Operations of the entries, Apply, Update or Create
+	Operations []metav1.ManagedFieldsOperationType `json:"operations,omitempty"`
+}
+
+// IgnoreList is a list of validated ignore rules
+type IgnoreList struct {
+	rules []compiledIgnoreRule
+}
+
+type compiledIgnoreRule struct {
+	IgnoreRule
+	patterns []PathPattern
+}
+
+// NewIgnoreList validates the rules, a write is ignored when any of them matches it.
+// A rule with every list empty would ignore every write and is an error.
+func NewIgnoreList(rules ...IgnoreRule) (*IgnoreList, error) {
+
+	list := &IgnoreList{}
+	errs := []error{}
+
+	for idx, rule := range rules {
+		compiled := compiledIgnoreRule{IgnoreRule: rule}
+
+		if len(rule.Managers) == 0 && len(rule.Paths) == 0 && len(rule.Subresources) == 0 && len(rule.Operations) == 0 {
+			errs = append(errs, fmt.Errorf("ignore rule %d: managers, paths, subresources or operations are required", idx))
+		}
+
+		for _, manager := range rule.Managers {
+			if _, err := path.Match(manager, ""); err != nil {
+				errs = append(errs, fmt.Errorf("ignore rule %d: invalid manager pattern %q: %w", idx, manager, err))
+			}
+		}
+
+		for _, p := range rule.Paths {
+			pattern, err := ParsePathPattern(p)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ignore rule %d: %w", idx, err))
+				continue
+			}
+			compiled.patterns = append(compiled.patterns, pattern)
+		}
+
+		for _, operation := range rule.Operations {
+			switch operation {
+			case metav1.ManagedFieldsOperationApply, metav1.ManagedFieldsOperationUpdate, "Create":
+			default:
+				errs = append(errs, fmt.Errorf("ignore rule %d: operation must be Apply, Update or Create, got %q", idx, operation))
+			}
+		}
+
+		list.rules = append(list.rules, compiled)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ParseIgnoreList parses a JSON or YAML list of ignore rules, e.g. the --ignore file of the plugin
+func ParseIgnoreList(data []byte) (*IgnoreList, error) {
+	rules := []IgnoreRule{}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing ignore rules: %w", err)
+	}
+	return NewIgnoreList(rules...)
+}
+
+// Ignores returns true if a rule matches the field path written by the entry.
+// A nil list ignores nothing.
+func (l *IgnoreList) Ignores(managedField metav1.ManagedFieldsEntry, fieldPath FieldPath) bool {
+	if l == nil {
+		return false
+	}
+	for _, rule := range l.rules {
+		if rule.matches(managedField, fieldPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledIgnoreRule) matches(managedField metav1.ManagedFieldsEntry, fieldPath FieldPath) bool {
+
+	if len(r.Managers) > 0 && !matchesManager(r.Managers, managedField.Manager) {
+		return false
+	}
+
+	if len(r.Subresources) > 0 && !slices.Contains(r.Subresources, managedField.Subresource) {
+		return false
+	}
+
+	if len(r.Operations) > 0 && !slices.Contains(r.Operations, managedField.Operation) {
+		return false
+	}
+
+	if len(r.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range r.patterns {
+		if pattern.Matches(fieldPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function matching the manager name, as written and normalized, with the patterns
+func matchesManager(patterns []string, manager string) bool {
+	normalized := NormalizeManager(manager)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, manager); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, normalized); matched {
+			return true
+		}
+	}
+	return false
+}
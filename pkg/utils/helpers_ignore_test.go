@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDetectFieldConflictsIgnore(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "stormforge-optimizer",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-edit",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecLimits(),
+			Manager:    "vpa-updater-v1.2.0",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-19T19:56:27Z")},
+		},
+	}
+
+	testCases := []struct {
+		desc     string
+		rules    []IgnoreRule
+		expected []string
+	}{
+		{
+			desc: "no rules",
+			expected: []string{
+				"kubectl-edit spec.template.spec.containers[nginx].resources.requests",
+				"vpa-updater-v1.2.0 spec.template.spec.containers[nginx].resources.limits",
+			},
+		},
+		{
+			desc:     "normalized manager",
+			rules:    []IgnoreRule{{Managers: []string{"vpa-updater"}}},
+			expected: []string{"kubectl-edit spec.template.spec.containers[nginx].resources.requests"},
+		},
+		{
+			desc:     "path",
+			rules:    []IgnoreRule{{Paths: []string{"**.requests"}}},
+			expected: []string{"vpa-updater-v1.2.0 spec.template.spec.containers[nginx].resources.limits"},
+		},
+		{
+			desc:  "manager and path",
+			rules: []IgnoreRule{{Managers: []string{"kubectl-*"}, Paths: []string{"**.limits"}}},
+			expected: []string{
+				"kubectl-edit spec.template.spec.containers[nginx].resources.requests",
+				"vpa-updater-v1.2.0 spec.template.spec.containers[nginx].resources.limits",
+			},
+		},
+		{
+			desc: "any rule",
+			rules: []IgnoreRule{
+				{Managers: []string{"kubectl-*"}, Paths: []string{"**.limits"}},
+				{Managers: []string{"kubectl-*"}, Paths: []string{"**.requests"}},
+			},
+			expected: []string{"vpa-updater-v1.2.0 spec.template.spec.containers[nginx].resources.limits"},
+		},
+		{
+			desc:     "operation",
+			rules:    []IgnoreRule{{Operations: []metav1.ManagedFieldsOperationType{metav1.ManagedFieldsOperationUpdate}}},
+			expected: []string{},
+		},
+		{
+			desc:  "subresource",
+			rules: []IgnoreRule{{Subresources: []string{"status"}}},
+			expected: []string{
+				"kubectl-edit spec.template.spec.containers[nginx].resources.requests",
+				"vpa-updater-v1.2.0 spec.template.spec.containers[nginx].resources.limits",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			list, err := NewIgnoreList(tc.rules...)
+			require.NoError(t, err)
+
+			actual := []string{}
+			for _, conflict := range DetectFieldConflicts("stormforge-optimizer", managedFields, WithIgnoreList(list)) {
+				actual = append(actual, fmt.Sprintf("%s %s", conflict.ExternalManager, conflict.Path.Human()))
+			}
+			assert.Equal(t, tc.expected, actual)
+
+			_, externalManager := DetectExternalManager("stormforge-optimizer", managedFields, WithIgnoreList(list))
+			if len(tc.expected) == 0 {
+				assert.Empty(t, externalManager)
+			} else {
+				assert.NotEmpty(t, externalManager)
+			}
+		})
+	}
+}
+
+func TestDetectFieldTakeoversIgnore(t *testing.T) {
+	oldManagedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "stormforge-optimizer",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+	}
+	newManagedFields := append(oldManagedFields, metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+		Manager:    "kubectl-edit",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+	})
+
+	assert.Len(t, DetectFieldTakeovers("stormforge-optimizer", oldManagedFields, newManagedFields), 1)
+
+	list, err := NewIgnoreList(IgnoreRule{Managers: []string{"kubectl-edit"}})
+	require.NoError(t, err)
+	assert.Empty(t, DetectFieldTakeovers("stormforge-optimizer", oldManagedFields, newManagedFields, WithIgnoreList(list)))
+}
+
+func TestNewIgnoreList(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		rules       []IgnoreRule
+		expectedErr string
+	}{
+		{
+			desc: "valid rules",
+			rules: []IgnoreRule{
+				{Managers: []string{"kube-controller-manager"}, Subresources: []string{"status"}},
+				{Managers: []string{"k8slens-*"}, Paths: []string{"metadata.labels"}, Operations: []metav1.ManagedFieldsOperationType{"Update"}},
+			},
+		},
+		{
+			desc: "invalid rules",
+			rules: []IgnoreRule{
+				{Managers: []string{"[kubectl"}},
+				{Paths: []string{"spec..replicas"}, Operations: []metav1.ManagedFieldsOperationType{"Delete"}},
+			},
+			expectedErr: `ignore rule 0: invalid manager pattern "[kubectl": syntax error in pattern
+ignore rule 1: invalid path "spec..replicas": empty field
+ignore rule 1: operation must be Apply, Update or Create, got "Delete"`,
+		},
+		{
+			desc:        "rule without criteria",
+			rules:       []IgnoreRule{{Managers: []string{"kube-controller-manager"}}, {}},
+			expectedErr: "ignore rule 1: managers, paths, subresources or operations are required",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			list, err := NewIgnoreList(tc.rules...)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, list.rules, len(tc.rules))
+		})
+	}
+}
+
+func TestParseIgnoreList(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		data          string
+		expectedRules int
+		expectedErr   string
+	}{
+		{
+			desc: "yaml",
+			data: `
+- managers: [kube-controller-manager]
+  subresources: [status]
+- managers: [k8slens-*]
+  paths: [metadata.labels]
+`,
+			expectedRules: 2,
+		},
+		{
+			desc:          "json",
+			data:          `[{"managers": ["kube-controller-manager"], "operations": ["Update"]}]`,
+			expectedRules: 1,
+		},
+		{
+			desc:        "unknown field",
+			data:        `[{"manager": "kube-controller-manager"}]`,
+			expectedErr: `parsing ignore rules: error unmarshaling JSON: while decoding JSON: json: unknown field "manager"`,
+		},
+		{
+			desc:        "invalid rule",
+			data:        `[{"paths": ["spec..replicas"]}]`,
+			expectedErr: `ignore rule 0: invalid path "spec..replicas": empty field`,
+		},
+		{
+			desc:        "rule without criteria",
+			data:        "- managers: [kube-controller-manager]\n- {}\n",
+			expectedErr: "ignore rule 1: managers, paths, subresources or operations are required",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			list, err := ParseIgnoreList([]byte(tc.data))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, list.rules, tc.expectedRules)
+		})
+	}
+}
@@ -112,6 +112,17 @@ func HPAManagedFieldsSpecMetrics() *metav1.FieldsV1 {
 	`)}
 }
 
+func HPAV2ManagedFieldsSpecMetricsAndMaxReplicas() *metav1.FieldsV1 {
+	return &metav1.FieldsV1{Raw: []byte(`
+	{
+		"f:spec": {
+			"f:maxReplicas": {},
+			"f:metrics":     {}
+		}
+	}
+	`)}
+}
+
 func AppsV1ManagedFieldsMetaAndSpecWithoutContainers() *metav1.FieldsV1 {
 	return &metav1.FieldsV1{Raw: []byte(`
 	{
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+)
+
+// ManagerCategory is the kind of client behind a field manager
+type ManagerCategory string
+
+const (
+	// HumanManager is a person using a CLI or a UI, e.g. kubectl or Lens
+	HumanManager ManagerCategory = "human"
+	// GitOpsManager is a deployment tool reconciling manifests, e.g. Argo CD, Flux or Helm
+	GitOpsManager ManagerCategory = "gitops"
+	// AutoscalerManager scales workloads or nodes, e.g. the HPA, the VPA or the cluster-autoscaler
+	AutoscalerManager ManagerCategory = "autoscaler"
+	// SystemManager is a Kubernetes control plane or node component
+	SystemManager ManagerCategory = "system"
+	// UnknownManager is any other manager
+	UnknownManager ManagerCategory = "unknown"
+)
+
+// ManagerCategories are the known categories
+var ManagerCategories = []ManagerCategory{HumanManager, GitOpsManager, AutoscalerManager, SystemManager, UnknownManager}
+
+// versions, architectures and extensions appended to manager names,
+// e.g. kubectl.exe, helm-v3.15.2, kustomize-controller/v1.3.0 or kubectl1.29.0
+var managerSuffixRegex = regexp.MustCompile(`(\.exe)?([-_@/ ]?v?\d+(\.\d+)+.*|/.*)?$`)
+
+// NormalizeManager returns the manager name without its version suffix
+// and executable extension, e.g. kubectl for kubectl.exe or helm for helm-v3.15.2
+func NormalizeManager(manager string) string {
+	normalized := managerSuffixRegex.ReplaceAllString(manager, "")
+	if normalized == "" {
+		return manager
+	}
+	return normalized
+}
+
+// ManagerClassifier maps the normalized manager names to their categories
+type ManagerClassifier struct {
+	mu sync.RWMutex
+	// patterns are path.Match patterns, the first matching one wins
+	patterns   []string
+	categories []ManagerCategory
+}
+
+// NewManagerClassifier returns an empty classifier, every manager is unknown
+func NewManagerClassifier() *ManagerClassifier {
+	return &ManagerClassifier{}
+}
+
+var (
+	defaultManagerClassifier     *ManagerClassifier
+	defaultManagerClassifierOnce sync.Once
+)
+
+// DefaultManagerClassifier returns the classifier used by the detection functions, with the well-known managers:
+//   - human: the kubectl variants (kubectl, kubectl-client-side-apply, kubectl-edit...), Lens (k8slens-edit), k9s
+//   - gitops: helm, argocd-controller, the Flux controllers (kustomize-controller, helm-controller)
+//   - autoscaler: horizontal-pod-autoscaler, the VPA components, cluster-autoscaler, keda-operator, karpenter
+//   - system: kube-controller-manager, kube-scheduler, kubelet, kube-apiserver, kube-proxy, cloud-controller-manager
+func DefaultManagerClassifier() *ManagerClassifier {
+	defaultManagerClassifierOnce.Do(func() {
+		defaultManagerClassifier = NewManagerClassifier()
+		registerBuiltInManagers(defaultManagerClassifier)
+	})
+	return defaultManagerClassifier
+}
+
+// ClassifyManager returns the category of the manager with the default classifier
+func ClassifyManager(manager string) ManagerCategory {
+	return DefaultManagerClassifier().Classify(manager)
+}
+
+// Register adds a path.Match pattern of normalized manager names, checked before the ones registered earlier,
+// e.g. Register("stormforge-*", AutoscalerManager)
+func (c *ManagerClassifier) Register(pattern string, category ManagerCategory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.patterns = append([]string{pattern}, c.patterns...)
+	c.categories = append([]ManagerCategory{category}, c.categories...)
+}
+
+// Classify returns the category of the normalized manager name, UnknownManager when no pattern matches it
+func (c *ManagerClassifier) Classify(manager string) ManagerCategory {
+	if c == nil {
+		return UnknownManager
+	}
+
+	normalized := NormalizeManager(manager)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for idx, pattern := range c.patterns {
+		if matched, _ := path.Match(pattern, normalized); matched {
+			return c.categories[idx]
+		}
+	}
+	return UnknownManager
+}
+
+func registerBuiltInManagers(c *ManagerClassifier) {
+
+	for _, manager := range []string{"kube-controller-manager", "kube-scheduler", "kubelet", "kube-apiserver", "kube-proxy", "cloud-controller-manager"} {
+		c.Register(manager, SystemManager)
+	}
+
+	for _, manager := range []string{"horizontal-pod-autoscaler", "vpa-*", "cluster-autoscaler", "keda-operator", "karpenter"} {
+		c.Register(manager, AutoscalerManager)
+	}
+
+	for _, manager := range []string{"helm", "argocd-*", "kustomize-controller", "helm-controller"} {
+		c.Register(manager, GitOpsManager)
+	}
+
+	for _, manager := range []string{"kubectl", "kubectl-*", "k8slens-*", "k9s"} {
+		c.Register(manager, HumanManager)
+	}
+}
+
+// ParseManagerCategory returns the known category of the name
+func ParseManagerCategory(name string) (ManagerCategory, error) {
+	for _, category := range ManagerCategories {
+		if string(category) == name {
+			return category, nil
+		}
+	}
+	return "", fmt.Errorf("unknown manager category %q", name)
+}
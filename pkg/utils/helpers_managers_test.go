@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeManager(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		manager  string
+		expected string
+	}{
+		{desc: "plain name", manager: "kubectl", expected: "kubectl"},
+		{desc: "windows executable", manager: "kubectl.exe", expected: "kubectl"},
+		{desc: "dashed version", manager: "helm-v3.15.2", expected: "helm"},
+		{desc: "user agent version", manager: "kustomize-controller/v1.3.0", expected: "kustomize-controller"},
+		{desc: "appended version", manager: "kubectl1.29.0", expected: "kubectl"},
+		{desc: "dashed name", manager: "k8slens-edit", expected: "k8slens-edit"},
+		{desc: "only a version", manager: "v1.2.3", expected: "v1.2.3"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeManager(tc.manager))
+		})
+	}
+}
+
+func TestClassifyManager(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		manager  string
+		expected ManagerCategory
+	}{
+		{desc: "kubectl apply", manager: "kubectl-client-side-apply", expected: HumanManager},
+		{desc: "kubectl on windows", manager: "kubectl.exe", expected: HumanManager},
+		{desc: "lens", manager: "k8slens-edit", expected: HumanManager},
+		{desc: "helm", manager: "helm-v3.15.2", expected: GitOpsManager},
+		{desc: "argo cd", manager: "argocd-controller", expected: GitOpsManager},
+		{desc: "flux", manager: "kustomize-controller/v1.3.0", expected: GitOpsManager},
+		{desc: "hpa", manager: "horizontal-pod-autoscaler", expected: AutoscalerManager},
+		{desc: "vpa", manager: "vpa-updater", expected: AutoscalerManager},
+		{desc: "controller manager", manager: "kube-controller-manager", expected: SystemManager},
+		{desc: "other", manager: "stormforge-optimizer", expected: UnknownManager},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			assert.Equal(t, tc.expected, ClassifyManager(tc.manager))
+		})
+	}
+}
+
+func TestManagerClassifierRegister(t *testing.T) {
+	classifier := NewManagerClassifier()
+	assert.Equal(t, UnknownManager, classifier.Classify("kubectl"))
+
+	classifier.Register("*", SystemManager)
+	classifier.Register("stormforge-*", AutoscalerManager)
+	assert.Equal(t, AutoscalerManager, classifier.Classify("stormforge-optimizer"))
+	assert.Equal(t, SystemManager, classifier.Classify("kubectl"))
+
+	var nilClassifier *ManagerClassifier
+	assert.Equal(t, UnknownManager, nilClassifier.Classify("kubectl"))
+}
+
+func TestParseManagerCategory(t *testing.T) {
+	for _, category := range ManagerCategories {
+		parsed, err := ParseManagerCategory(string(category))
+		require.NoError(t, err)
+		assert.Equal(t, category, parsed)
+	}
+
+	_, err := ParseManagerCategory("robot")
+	assert.EqualError(t, err, `unknown manager category "robot"`)
+}
+
+func TestDetectFieldConflictsCategory(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "stormforge-optimizer",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "vpa-updater",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: time.Date(2024, 9, 1, 11, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	classifier := NewManagerClassifier()
+	classifier.Register("vpa-*", GitOpsManager)
+
+	testCases := []struct {
+		desc     string
+		options  []DetectOption
+		expected ManagerCategory
+	}{
+		{desc: "default classifier", expected: AutoscalerManager},
+		{desc: "custom classifier", options: []DetectOption{WithManagerClassifier(classifier)}, expected: GitOpsManager},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			conflicts := DetectFieldConflicts("stormforge-optimizer", managedFields, tc.options...)
+			require.NotEmpty(t, conflicts)
+			for _, conflict := range conflicts {
+				assert.Equal(t, tc.expected, conflict.Category)
+			}
+		})
+	}
+}
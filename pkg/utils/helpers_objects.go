@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ToUnstructured returns the object as unstructured, it accepts an *unstructured.Unstructured (returned as is),
+// a runtime.Object or a metav1.Object such as a typed object, and the JSON or YAML of an object as []byte
+func ToUnstructured(object interface{}) (*unstructured.Unstructured, error) {
+
+	if isNil(object) {
+		return nil, fmt.Errorf("object nil")
+	}
+
+	switch o := object.(type) {
+	case *unstructured.Unstructured:
+		return o, nil
+	case []byte:
+		data, err := yaml.YAMLToJSON(o)
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return u, nil
+	case runtime.Object:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return nil, err
+		}
+		return &unstructured.Unstructured{Object: content}, nil
+	case metav1.Object:
+		// e.g. an *ObjectMeta, the metadata of an object only
+		metadata, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return nil, err
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{"metadata": metadata}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported object type %T", object)
+}
+
+// ManagedFieldsOf returns the managed fields of an object of any type accepted by ToUnstructured
+func ManagedFieldsOf(object interface{}) ([]metav1.ManagedFieldsEntry, error) {
+	if o, ok := object.(metav1.Object); ok && !isNil(o) {
+		return o.GetManagedFields(), nil
+	}
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	return u.GetManagedFields(), nil
+}
+
+// Helper function returning true if the object is nil or a nil pointer, e.g. a (*appsv1.Deployment)(nil)
+func isNil(object interface{}) bool {
+	if object == nil {
+		return true
+	}
+	v := reflect.ValueOf(object)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// DetectExternalManagerInObject is DetectExternalManagerWithErrors for an object of any type accepted by ToUnstructured
+func DetectExternalManagerInObject(originalManager string, object interface{}, opts ...DetectOption) (bool, string, error) {
+	managedFields, err := ManagedFieldsOf(object)
+	if err != nil {
+		return false, "", err
+	}
+	return DetectExternalManagerWithErrors(originalManager, managedFields, opts...)
+}
+
+// DetectFieldConflictsInObject is DetectFieldConflictsWithErrors for an object of any type accepted by ToUnstructured,
+// with the live values of the fields in the conflicts
+func DetectFieldConflictsInObject(originalManager string, object interface{}, opts ...DetectOption) ([]FieldConflict, error) {
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := DetectFieldConflictsWithErrors(originalManager, u.GetManagedFields(), opts...)
+	setValues(conflicts, u, newDetectOptions(opts))
+	return conflicts, err
+}
+
+// DetectFieldTakeoversInObjects is DetectFieldTakeoversWithErrors for the old and new versions of an object,
+// of any type accepted by ToUnstructured, with the live values of the fields of the new version in the takeovers.
+// A field an external manager already wrote in the old version is a takeover as well when its value changed.
+func DetectFieldTakeoversInObjects(originalManager string, oldObject, newObject interface{}, opts ...DetectOption) ([]FieldConflict, error) {
+	oldU, err := ToUnstructured(oldObject)
+	if err != nil {
+		return nil, err
+	}
+	u, err := ToUnstructured(newObject)
+	if err != nil {
+		return nil, err
+	}
+
+	options := newDetectOptions(opts)
+
+	// the fields missing from either version, e.g. of an old object with its metadata only, are not compared
+	changed := func(conflict FieldConflict) bool {
+		oldValue, oldFound := valueOf(conflict, oldU, options)
+		newValue, newFound := valueOf(conflict, u, options)
+		return oldFound && newFound && !reflect.DeepEqual(oldValue, newValue)
+	}
+
+	takeovers, err := detectFieldTakeovers(originalManager, oldU.GetManagedFields(), u.GetManagedFields(), changed, options)
+	setValues(takeovers, u, options)
+	return takeovers, err
+}
+
+// Helper function setting the values of the conflicts from the object
+func setValues(conflicts []FieldConflict, u *unstructured.Unstructured, options *detectOptions) {
+	for idx := range conflicts {
+		if value, found := valueOf(conflicts[idx], u, options); found {
+			conflicts[idx].Value = value
+		}
+	}
+}
+
+// Helper function returning the value of the field of the conflict in the object,
+// with its path converted to the API version of the object
+func valueOf(conflict FieldConflict, u *unstructured.Unstructured, options *detectOptions) (interface{}, bool) {
+	for _, path := range options.conversions.Convert(conflict.Path, conflict.APIVersion, u.GetAPIVersion()) {
+		if value, found := FieldValue(u.Object, path); found {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// FieldValue returns the value of the field path in the content of an unstructured object,
+// e.g. the map of the resources of spec.template.spec.containers[nginx].resources
+func FieldValue(content map[string]interface{}, path FieldPath) (interface{}, bool) {
+
+	var current interface{} = content
+
+	for _, element := range path {
+		switch element.Kind {
+		case SelfElement:
+			continue
+
+		case FieldElement:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[element.Value]; !ok {
+				return nil, false
+			}
+
+		case KeyElement:
+			var key map[string]interface{}
+			if err := json.Unmarshal([]byte(element.Value), &key); err != nil {
+				return nil, false
+			}
+			item, ok := findItem(current, func(item interface{}) bool {
+				itemMap, ok := item.(map[string]interface{})
+				if !ok {
+					return false
+				}
+				for name, value := range key {
+					if !jsonEqual(itemMap[name], value) {
+						return false
+					}
+				}
+				return true
+			})
+			if !ok {
+				return nil, false
+			}
+			current = item
+
+		case ValueElement:
+			var value interface{}
+			if err := json.Unmarshal([]byte(element.Value), &value); err != nil {
+				return nil, false
+			}
+			item, ok := findItem(current, func(item interface{}) bool {
+				return jsonEqual(item, value)
+			})
+			if !ok {
+				return nil, false
+			}
+			current = item
+
+		case IndexElement:
+			list, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			index, err := strconv.Atoi(element.Value)
+			if err != nil || index < 0 || index >= len(list) {
+				return nil, false
+			}
+			current = list[index]
+		}
+	}
+
+	return current, true
+}
+
+func findItem(list interface{}, matches func(interface{}) bool) (interface{}, bool) {
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	for _, item := range items {
+		if matches(item) {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// Helper function comparing values as JSON, e.g. the int64 of an object with the float64 of a key
+func jsonEqual(a, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const deploymentYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  finalizers:
+  - example.com/cleanup
+  managedFields:
+  - apiVersion: apps/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:template:
+          f:spec:
+            f:containers:
+              k:{"name":"nginx"}:
+                f:resources:
+                  f:requests: {}
+    manager: original-manager
+    operation: Apply
+    time: "2024-06-17T19:56:27Z"
+  - apiVersion: apps/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:template:
+          f:spec:
+            f:containers:
+              k:{"name":"nginx"}:
+                f:resources:
+                  f:requests:
+                    f:cpu: {}
+    manager: kubectl-edit
+    operation: Update
+    time: "2024-06-18T19:56:27Z"
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx
+        ports:
+        - containerPort: 80
+          protocol: TCP
+        resources:
+          requests:
+            cpu: 250m
+`
+
+func deployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   compact(AppsV1ManagedFieldsMetaAndSpecRequests()),
+					Manager:    "original-manager",
+					Operation:  "Apply",
+					Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+				},
+				{
+					APIVersion: "apps/v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   compact(AppsV1ManagedFieldsMetaAndSpecRequests()),
+					Manager:    "kubectl-edit",
+					Operation:  "Update",
+					Time:       &metav1.Time{Time: MustParseTime("2024-06-18T19:56:27Z")},
+				},
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "nginx",
+							Image: "nginx",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDetectFieldConflictsInObject(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	require.NoError(t, u.UnmarshalJSON(mustYAMLToJSON(t, deploymentYAML)))
+
+	testCases := []struct {
+		desc          string
+		object        interface{}
+		expectedPath  string
+		expectedValue interface{}
+	}{
+		{
+			desc:          "typed object",
+			object:        deployment(),
+			expectedPath:  "spec.template.spec.containers[nginx].resources.requests",
+			expectedValue: map[string]interface{}{"cpu": "250m"},
+		},
+		{
+			desc:          "unstructured object",
+			object:        u,
+			expectedPath:  "spec.template.spec.containers[nginx].resources.requests.cpu",
+			expectedValue: "250m",
+		},
+		{
+			desc:          "YAML",
+			object:        []byte(deploymentYAML),
+			expectedPath:  "spec.template.spec.containers[nginx].resources.requests.cpu",
+			expectedValue: "250m",
+		},
+		{
+			desc:          "JSON",
+			object:        mustYAMLToJSON(t, deploymentYAML),
+			expectedPath:  "spec.template.spec.containers[nginx].resources.requests.cpu",
+			expectedValue: "250m",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			conflicts, err := DetectFieldConflictsInObject("original-manager", tc.object)
+			require.NoError(t, err)
+			require.Len(t, conflicts, 1)
+			assert.Equal(t, "kubectl-edit", conflicts[0].ExternalManager)
+			assert.Equal(t, tc.expectedPath, conflicts[0].Path.Human())
+			assert.Equal(t, tc.expectedValue, conflicts[0].Value)
+
+			overwritten, externalManager, err := DetectExternalManagerInObject("original-manager", tc.object)
+			require.NoError(t, err)
+			assert.True(t, overwritten)
+			assert.Equal(t, "kubectl-edit", externalManager)
+		})
+	}
+}
+
+func TestDetectFieldTakeoversInObjects(t *testing.T) {
+	oldObject := deployment()
+	oldObject.ManagedFields = oldObject.ManagedFields[:1]
+
+	takeovers, err := DetectFieldTakeoversInObjects("original-manager", oldObject, deployment())
+	require.NoError(t, err)
+	require.Len(t, takeovers, 1)
+	assert.Equal(t, "kubectl-edit", takeovers[0].ExternalManager)
+	assert.Equal(t, map[string]interface{}{"cpu": "250m"}, takeovers[0].Value)
+
+	// kubectl-edit co-owning the requests in both versions, writing them again or not
+	coOwned := deployment()
+	coOwned.ManagedFields[1].Time = &metav1.Time{Time: MustParseTime("2024-06-19T19:56:27Z")}
+
+	takeovers, err = DetectFieldTakeoversInObjects("original-manager", deployment(), coOwned)
+	require.NoError(t, err)
+	assert.Empty(t, takeovers)
+
+	coOwned.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("500m")
+	takeovers, err = DetectFieldTakeoversInObjects("original-manager", deployment(), coOwned)
+	require.NoError(t, err)
+	require.Len(t, takeovers, 1)
+	assert.Equal(t, map[string]interface{}{"cpu": "500m"}, takeovers[0].Value)
+}
+
+func TestToUnstructured(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		object      interface{}
+		expectedErr string
+	}{
+		{desc: "typed object", object: deployment()},
+		{desc: "object metadata", object: &metav1.ObjectMeta{Name: "nginx"}},
+		{desc: "YAML", object: []byte(deploymentYAML)},
+		{desc: "nil", object: nil, expectedErr: "object nil"},
+		{desc: "nil unstructured", object: (*unstructured.Unstructured)(nil), expectedErr: "object nil"},
+		{desc: "nil typed object", object: (*appsv1.Deployment)(nil), expectedErr: "object nil"},
+		{desc: "nil object metadata", object: (*metav1.ObjectMeta)(nil), expectedErr: "object nil"},
+		{desc: "unsupported type", object: deploymentYAML, expectedErr: "unsupported object type string"},
+		{desc: "YAML without kind", object: []byte("metadata:\n  name: nginx\n"), expectedErr: "Object 'Kind' is missing in '{\"metadata\":{\"name\":\"nginx\"}}'"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			u, err := ToUnstructured(tc.object)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "nginx", u.GetName())
+		})
+	}
+}
+
+func TestDetectInNilObject(t *testing.T) {
+	_, err := ManagedFieldsOf((*appsv1.Deployment)(nil))
+	assert.EqualError(t, err, "object nil")
+
+	_, _, err = DetectExternalManagerInObject("original-manager", (*appsv1.Deployment)(nil))
+	assert.EqualError(t, err, "object nil")
+
+	_, err = DetectFieldTakeoversInObjects("original-manager", (*appsv1.Deployment)(nil), deployment())
+	assert.EqualError(t, err, "object nil")
+}
+
+func TestFieldValue(t *testing.T) {
+	u, err := ToUnstructured([]byte(deploymentYAML))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc          string
+		path          []string
+		expectedValue interface{}
+		expectedFound bool
+	}{
+		{
+			desc:          "field",
+			path:          []string{"f:metadata", "f:name"},
+			expectedValue: "nginx",
+			expectedFound: true,
+		},
+		{
+			desc:          "set value",
+			path:          []string{"f:metadata", "f:finalizers", `v:"example.com/cleanup"`},
+			expectedValue: "example.com/cleanup",
+			expectedFound: true,
+		},
+		{
+			desc:          "numeric key",
+			path:          []string{"f:spec", "f:template", "f:spec", "f:containers", `k:{"name":"nginx"}`, "f:ports", `k:{"containerPort":80,"protocol":"TCP"}`, "f:protocol"},
+			expectedValue: "TCP",
+			expectedFound: true,
+		},
+		{
+			desc:          "index",
+			path:          []string{"f:spec", "f:template", "f:spec", "f:containers", "i:0", "f:image"},
+			expectedValue: "nginx",
+			expectedFound: true,
+		},
+		{
+			desc:          "item itself",
+			path:          []string{"f:spec", "f:template", "f:spec", "f:containers", `k:{"name":"nginx"}`, ".", "f:name"},
+			expectedValue: "nginx",
+			expectedFound: true,
+		},
+		{
+			desc: "missing item",
+			path: []string{"f:spec", "f:template", "f:spec", "f:containers", `k:{"name":"sidecar"}`},
+		},
+		{
+			desc: "missing field",
+			path: []string{"f:spec", "f:replicas"},
+		},
+		{
+			desc: "index out of range",
+			path: []string{"f:spec", "f:template", "f:spec", "f:containers", "i:1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			path := FieldPath{}
+			for _, key := range tc.path {
+				element, err := ParsePathElement(key)
+				require.NoError(t, err)
+				path = append(path, element)
+			}
+
+			value, found := FieldValue(u.Object, path)
+			assert.Equal(t, tc.expectedFound, found)
+			assert.Equal(t, tc.expectedValue, value)
+		})
+	}
+}
+
+// compact trims the fixtures, the converter of typed objects into unstructured
+// takes FieldsV1 starting with a space for numbers
+func compact(fieldsV1 *metav1.FieldsV1) *metav1.FieldsV1 {
+	return &metav1.FieldsV1{Raw: bytes.TrimSpace(fieldsV1.Raw)}
+}
+
+func mustYAMLToJSON(t *testing.T, data string) []byte {
+	u, err := ToUnstructured([]byte(data))
+	require.NoError(t, err)
+	json, err := u.MarshalJSON()
+	require.NoError(t, err)
+	return json
+}
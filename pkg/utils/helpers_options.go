@@ -0,0 +1,92 @@
+package utils
+
+// DetectOption configures the detection functions
+type DetectOption func(*detectOptions)
+
+type detectOptions struct {
+	conversions *ConversionRegistry
+	classifier  *ManagerClassifier
+	ignore      *IgnoreList
+	cache       *ParseCache
+	fieldSets   bool
+	create      bool
+}
+
+func newDetectOptions(opts []DetectOption) *detectOptions {
+	options := &detectOptions{
+		conversions: DefaultConversionRegistry(),
+		classifier:  DefaultManagerClassifier(),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithConversions sets the registry converting the paths of the entries written with
+// another API version than the original manager, DefaultConversionRegistry by default.
+// A nil registry compares the paths as they are.
+func WithConversions(registry *ConversionRegistry) DetectOption {
+	return func(o *detectOptions) {
+		o.conversions = registry
+	}
+}
+
+// WithFieldSets matches the fields as structured-merge-diff sets, like the apiserver does:
+// a field conflicts only when both managers own the exact same path,
+// instead of matching the paths of the original manager on any list item
+func WithFieldSets() DetectOption {
+	return func(o *detectOptions) {
+		o.fieldSets = true
+	}
+}
+
+// WithManagerClassifier sets the classifier of the external managers of the conflicts,
+// DefaultManagerClassifier by default
+func WithManagerClassifier(classifier *ManagerClassifier) DetectOption {
+	return func(o *detectOptions) {
+		o.classifier = classifier
+	}
+}
+
+// WithIgnoreList sets the rules of the writes of external managers that are not conflicts,
+// nothing is ignored by default
+func WithIgnoreList(list *IgnoreList) DetectOption {
+	return func(o *detectOptions) {
+		o.ignore = list
+	}
+}
+
+// WithCreate includes the entries of Create operations, skipped by default,
+// both as entries of the original manager and of the external managers
+func WithCreate() DetectOption {
+	return func(o *detectOptions) {
+		o.create = true
+	}
+}
+
+// WithParseCache parses the FieldsV1 of the entries with the cache, shared by the calls of
+// the detection functions, e.g. of a watcher, so the unchanged entries are parsed once.
+// Without a cache every call parses every entry.
+func WithParseCache(cache *ParseCache) DetectOption {
+	return func(o *detectOptions) {
+		o.cache = cache
+	}
+}
+
+// IgnoreListOf returns the ignore list set by the options, nil when none,
+// for the analyses of the managed fields outside of the detection functions, e.g. policies
+func IgnoreListOf(opts ...DetectOption) *IgnoreList {
+	return newDetectOptions(opts).ignore
+}
+
+// ParseCacheOf returns the parse cache set by the options, nil when none,
+// a nil cache parses every time
+func ParseCacheOf(opts ...DetectOption) *ParseCache {
+	return newDetectOptions(opts).cache
+}
+
+// ManagerClassifierOf returns the classifier set by the options, DefaultManagerClassifier by default
+func ManagerClassifierOf(opts ...DetectOption) *ManagerClassifier {
+	return newDetectOptions(opts).classifier
+}
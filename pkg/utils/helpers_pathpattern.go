@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	// a field name or "*" for any field
+	fieldSegment segmentKind = iota
+	// a list item in brackets, "[*]" for any item
+	itemSegment
+	// "**", any number of levels
+	anyLevelsSegment
+)
+
+type segment struct {
+	kind segmentKind
+	// field name, or bare value of the item
+	value string
+	// key=value pairs of the item
+	pairs map[string]string
+	any   bool
+}
+
+// PathPattern is a parsed path glob, matching the fields under the paths it matches
+type PathPattern []segment
+
+// ParsePathPattern parses a dotted path glob, e.g. spec.template.spec.containers[*].resources
+// or metadata.annotations["example.com/owner"] for field names with dots.
+// "*" matches a field, "[*]" any list item, "[nginx]" or "[name=nginx]" a given item
+// and "**" any number of levels.
+func ParsePathPattern(s string) (PathPattern, error) {
+
+	pattern := PathPattern{}
+
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			if i == 0 || i == len(s)-1 || s[i-1] == '.' {
+				return nil, fmt.Errorf("invalid path %q: empty field", s)
+			}
+			i++
+		case '[':
+			end := closingBracket(s, i)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated [", s)
+			}
+			item, err := parseItem(s[i+1 : end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", s, err)
+			}
+			pattern = append(pattern, item)
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && s[end] != '.' && s[end] != '[' {
+				end++
+			}
+			name := s[i:end]
+			switch {
+			case name == "**":
+				pattern = append(pattern, segment{kind: anyLevelsSegment})
+			case name == "*":
+				pattern = append(pattern, segment{kind: fieldSegment, any: true})
+			case strings.ContainsAny(name, "*]\""):
+				return nil, fmt.Errorf("invalid path %q: invalid field %q", s, name)
+			default:
+				pattern = append(pattern, segment{kind: fieldSegment, value: name})
+			}
+			i = end
+		}
+	}
+
+	return pattern, nil
+}
+
+// Helper function returning the index of the bracket closing the one at start,
+// skipping quoted strings
+func closingBracket(s string, start int) int {
+	quoted := false
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ']' && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+// Helper function parsing the content of brackets: "*", a quoted field name,
+// key=value pairs or a bare value
+func parseItem(content string) (segment, error) {
+	switch {
+	case content == "*":
+		return segment{kind: itemSegment, any: true}, nil
+	case strings.HasPrefix(content, `"`):
+		var name string
+		if err := json.Unmarshal([]byte(content), &name); err != nil {
+			return segment{}, fmt.Errorf("invalid quoted field %s", content)
+		}
+		return segment{kind: fieldSegment, value: name}, nil
+	case content == "":
+		return segment{}, fmt.Errorf("empty []")
+	case strings.Contains(content, "="):
+		pairs := map[string]string{}
+		for _, pair := range strings.Split(content, ",") {
+			name, value, found := strings.Cut(pair, "=")
+			if !found || name == "" {
+				return segment{}, fmt.Errorf("invalid key pair %q", pair)
+			}
+			pairs[name] = value
+		}
+		return segment{kind: itemSegment, pairs: pairs}, nil
+	}
+	return segment{kind: itemSegment, value: content}, nil
+}
+
+// Matches returns true if the pattern matches the field path or one of its parents
+func (p PathPattern) Matches(fieldPath FieldPath) bool {
+	elements := make(FieldPath, 0, len(fieldPath))
+	for _, element := range fieldPath {
+		if element.Kind != SelfElement {
+			elements = append(elements, element)
+		}
+	}
+	return matchPrefix(p, elements)
+}
+
+func matchPrefix(pattern PathPattern, elements FieldPath) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+
+	if pattern[0].kind == anyLevelsSegment {
+		for skip := 0; skip <= len(elements); skip++ {
+			if matchPrefix(pattern[1:], elements[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(elements) == 0 || !pattern[0].matches(elements[0]) {
+		return false
+	}
+
+	return matchPrefix(pattern[1:], elements[1:])
+}
+
+func (s segment) matches(element PathElement) bool {
+	if s.kind == fieldSegment {
+		return element.Kind == FieldElement && (s.any || s.value == element.Value)
+	}
+
+	if element.Kind == FieldElement {
+		return false
+	}
+
+	if s.any {
+		return true
+	}
+
+	if s.pairs == nil {
+		return s.value == element.Human()
+	}
+
+	if element.Kind != KeyElement {
+		return false
+	}
+
+	var key map[string]interface{}
+	if err := json.Unmarshal([]byte(element.Value), &key); err != nil {
+		return false
+	}
+	for name, value := range s.pairs {
+		actual, found := key[name]
+		if !found || fmt.Sprint(actual) != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPatternMatches(t *testing.T) {
+	nginx := `k:{"name":"nginx"}`
+	testCases := []struct {
+		pattern  string
+		path     []string
+		expected bool
+	}{
+		{"spec", []string{"f:spec", "f:replicas"}, true},
+		{"spec.template.spec.containers[*].resources", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:resources", "f:requests"}, true},
+		{"spec.template.spec.containers[nginx].resources", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:resources", "f:limits"}, true},
+		{"spec.template.spec.containers[name=nginx]", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:args"}, true},
+		{"spec.template.spec.containers[sidecar]", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:args"}, false},
+		{"spec.template.spec.containers[*].resources", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:args"}, false},
+		{"**.resources", []string{"f:spec", "f:template", "f:spec", "f:containers", nginx, "f:resources", "f:requests"}, true},
+		{"spec.*", []string{"f:spec", "f:replicas"}, true},
+		{"spec.*", []string{"f:spec", "."}, false},
+		{`metadata.annotations["kubernetes.io/change-cause"]`, []string{"f:metadata", "f:annotations", "f:kubernetes.io/change-cause"}, true},
+		{"spec.ports[port=80,protocol=TCP]", []string{"f:spec", "f:ports", `k:{"port":80,"protocol":"TCP"}`, "."}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %v", tc.pattern, tc.path), func(t *testing.T) {
+			pattern, err := ParsePathPattern(tc.pattern)
+			require.NoError(t, err)
+
+			fieldPath := FieldPath{}
+			for _, key := range tc.path {
+				element, err := ParsePathElement(key)
+				require.NoError(t, err)
+				fieldPath = append(fieldPath, element)
+			}
+			assert.Equal(t, tc.expected, pattern.Matches(fieldPath))
+		})
+	}
+}
+
+func TestParsePathPatternErrors(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		pattern     string
+		expectedErr string
+	}{
+		{desc: "empty", pattern: "", expectedErr: "empty path"},
+		{desc: "empty field", pattern: "spec..replicas", expectedErr: `invalid path "spec..replicas": empty field`},
+		{desc: "unterminated item", pattern: "spec.containers[nginx", expectedErr: `invalid path "spec.containers[nginx": unterminated [`},
+		{desc: "empty item", pattern: "spec.containers[]", expectedErr: `invalid path "spec.containers[]": empty []`},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			_, err := ParsePathPattern(tc.pattern)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// FieldsV1ToSet converts the FieldsV1 into the structured-merge-diff
+// field set the apiserver uses
+func FieldsV1ToSet(fieldsV1 *metav1.FieldsV1) (*fieldpath.Set, error) {
+	if fieldsV1 == nil {
+		return nil, fmt.Errorf("fieldsV1 nil")
+	}
+
+	set := &fieldpath.Set{}
+	if err := set.FromJSON(bytes.NewReader(bytes.TrimSpace(fieldsV1.Raw))); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// SetToFieldsV1 converts a structured-merge-diff field set into FieldsV1
+func SetToFieldsV1(set *fieldpath.Set) (*metav1.FieldsV1, error) {
+	raw, err := set.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.FieldsV1{Raw: raw}, nil
+}
+
+// ToSMD converts the field path into a structured-merge-diff path,
+// the "." element is the parent itself and is dropped
+func (p FieldPath) ToSMD() (fieldpath.Path, error) {
+	path := make(fieldpath.Path, 0, len(p))
+	for _, element := range p {
+		if element.Kind == SelfElement {
+			continue
+		}
+		pe, err := fieldpath.DeserializePathElement(string(element.Kind) + ":" + element.Value)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, pe)
+	}
+	return path, nil
+}
+
+// FieldPathFromSMD converts a structured-merge-diff path into a field path
+func FieldPathFromSMD(path fieldpath.Path) (FieldPath, error) {
+	fieldPath := make(FieldPath, 0, len(path))
+	for _, pe := range path {
+		key, err := fieldpath.SerializePathElement(pe)
+		if err != nil {
+			return nil, err
+		}
+		element, err := ParsePathElement(key)
+		if err != nil {
+			return nil, err
+		}
+		fieldPath = append(fieldPath, element)
+	}
+	return fieldPath, nil
+}
+
+// ManagerIdentifier returns the identifier of the entry in structured-merge-diff managed fields,
+// built as the apiserver does: the JSON entry without its fields and time,
+// and without the API version for appliers
+func ManagerIdentifier(managedField metav1.ManagedFieldsEntry) (string, error) {
+	managedField.FieldsV1 = nil
+	managedField.FieldsType = ""
+	managedField.Time = nil
+	if managedField.Operation == metav1.ManagedFieldsOperationApply {
+		managedField.APIVersion = ""
+	}
+
+	identifier, err := json.Marshal(&managedField)
+	if err != nil {
+		return "", err
+	}
+	return string(identifier), nil
+}
+
+// ParseManagerIdentifier returns the entry, without fields and time, of an identifier of ManagerIdentifier,
+// other identifiers are taken as the names of Update managers
+func ParseManagerIdentifier(identifier string) metav1.ManagedFieldsEntry {
+	return decodeManagerIdentifier(identifier, false)
+}
+
+// ManagedFieldsToSMD converts the managed fields into structured-merge-diff managed fields,
+// keyed by ManagerIdentifier, with the times of the entries by the same keys
+func ManagedFieldsToSMD(managedFields []metav1.ManagedFieldsEntry) (fieldpath.ManagedFields, map[string]*metav1.Time, error) {
+
+	managed := fieldpath.ManagedFields{}
+	times := map[string]*metav1.Time{}
+
+	for idx, managedField := range managedFields {
+		identifier, err := ManagerIdentifier(managedField)
+		if err != nil {
+			return nil, nil, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err)
+		}
+		if _, found := managed[identifier]; found {
+			return nil, nil, fmt.Errorf("managedFields[%d] of %s: duplicate entry %s", idx, managedField.Manager, identifier)
+		}
+
+		set := &fieldpath.Set{}
+		if managedField.FieldsV1 != nil {
+			set, err = FieldsV1ToSet(managedField.FieldsV1)
+			if err != nil {
+				return nil, nil, fmt.Errorf("managedFields[%d] of %s: %w", idx, managedField.Manager, err)
+			}
+		}
+
+		applied := managedField.Operation == metav1.ManagedFieldsOperationApply
+		managed[identifier] = fieldpath.NewVersionedSet(set, fieldpath.APIVersion(managedField.APIVersion), applied)
+		times[identifier] = managedField.Time
+	}
+
+	return managed, times, nil
+}
+
+// ManagedFieldsFromSMD converts structured-merge-diff managed fields back into managed fields,
+// sorted by time and manager. Plain manager names are accepted as identifiers as well,
+// as Update operations.
+func ManagedFieldsFromSMD(managed fieldpath.ManagedFields, times map[string]*metav1.Time) ([]metav1.ManagedFieldsEntry, error) {
+
+	managedFields := make([]metav1.ManagedFieldsEntry, 0, len(managed))
+
+	for identifier, versionedSet := range managed {
+		managedField := decodeManagerIdentifier(identifier, versionedSet.Applied())
+
+		if managedField.APIVersion == "" {
+			managedField.APIVersion = string(versionedSet.APIVersion())
+		}
+
+		fieldsV1, err := SetToFieldsV1(versionedSet.Set())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", identifier, err)
+		}
+		managedField.FieldsType = "FieldsV1"
+		managedField.FieldsV1 = fieldsV1
+		managedField.Time = times[identifier]
+
+		managedFields = append(managedFields, managedField)
+	}
+
+	sort.Slice(managedFields, func(i, j int) bool {
+		ti, tj := managedFields[i].Time, managedFields[j].Time
+		switch {
+		case ti == nil && tj != nil:
+			return false
+		case ti != nil && tj == nil:
+			return true
+		case ti != nil && !ti.Equal(tj):
+			return ti.Before(tj)
+		}
+		return managedFields[i].Manager < managedFields[j].Manager
+	})
+
+	return managedFields, nil
+}
+
+// DetectSetConflicts returns, for every other manager, the fields it shares with the original manager,
+// the way the apiserver computes conflicts: by intersecting the field sets
+func DetectSetConflicts(originalManager string, managed fieldpath.ManagedFields) map[string]*fieldpath.Set {
+
+	conflicts := map[string]*fieldpath.Set{}
+
+	original := &fieldpath.Set{}
+	for identifier, versionedSet := range managed {
+		if decodeManagerIdentifier(identifier, versionedSet.Applied()).Manager == originalManager {
+			original = original.Union(versionedSet.Set())
+		}
+	}
+
+	if original.Empty() {
+		return conflicts
+	}
+
+	for identifier, versionedSet := range managed {
+		if decodeManagerIdentifier(identifier, versionedSet.Applied()).Manager == originalManager {
+			continue
+		}
+		if shared := original.Intersection(versionedSet.Set()); !shared.Empty() {
+			conflicts[identifier] = shared
+		}
+	}
+
+	return conflicts
+}
+
+// Helper function decoding the identifiers of ManagerIdentifier,
+// other identifiers are the manager names
+func decodeManagerIdentifier(identifier string, applied bool) metav1.ManagedFieldsEntry {
+	var managedField metav1.ManagedFieldsEntry
+	if err := json.Unmarshal([]byte(identifier), &managedField); err == nil && managedField.Manager != "" {
+		return managedField
+	}
+
+	managedField = metav1.ManagedFieldsEntry{Manager: identifier, Operation: metav1.ManagedFieldsOperationUpdate}
+	if applied {
+		managedField.Operation = metav1.ManagedFieldsOperationApply
+	}
+	return managedField
+}
+
+// Helper function returning the set of the original entry, to match the
+// paths of the other managers exactly instead of with regexes, with the cache when not nil
+func setMatcher(originalEntry metav1.ManagedFieldsEntry, cache *ParseCache) (func([]FieldPath) bool, error) {
+	set, err := cache.FieldsV1ToSet(originalEntry.FieldsV1)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(paths []FieldPath) bool {
+		for _, path := range paths {
+			smdPath, err := path.ToSMD()
+			if err != nil {
+				continue
+			}
+			if set.Has(smdPath) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestFieldsV1ToSet(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		fieldsV1 *metav1.FieldsV1
+	}{
+		{
+			desc:     "deployment with containers",
+			fieldsV1: AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			desc:     "deployment without containers",
+			fieldsV1: AppsV1ManagedFieldsMetaAndSpecWithoutContainers(),
+		},
+		{
+			desc:     "hpa status",
+			fieldsV1: HPAManagedFieldsStatus(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			expected, err := ParseFieldsV1(tc.fieldsV1)
+			require.NoError(t, err)
+
+			set, err := FieldsV1ToSet(tc.fieldsV1)
+			require.NoError(t, err)
+
+			// every leaf path is in the set
+			for _, path := range expected {
+				smdPath, err := path.ToSMD()
+				require.NoError(t, err)
+				assert.True(t, set.Has(smdPath), path.Human())
+
+				fieldPath, err := FieldPathFromSMD(smdPath)
+				require.NoError(t, err)
+				if path[len(path)-1].Kind == SelfElement {
+					path = path[:len(path)-1]
+				}
+				assert.Equal(t, path, fieldPath)
+			}
+
+			fieldsV1, err := SetToFieldsV1(set)
+			require.NoError(t, err)
+
+			actual, err := ParseFieldsV1(fieldsV1)
+			require.NoError(t, err)
+			assert.Equal(t, humanPaths(expected), humanPaths(actual))
+		})
+	}
+}
+
+func TestManagedFieldsToSMD(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpec(),
+			Manager:    "original-manager",
+			Operation:  "Apply",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+		},
+	}
+
+	managed, times, err := ManagedFieldsToSMD(managedFields)
+	require.NoError(t, err)
+
+	applier := `{"manager":"original-manager","operation":"Apply"}`
+	updater := `{"manager":"kubectl-client-side-apply","operation":"Update","apiVersion":"apps/v1"}`
+	require.Contains(t, managed, applier)
+	require.Contains(t, managed, updater)
+	assert.True(t, managed[applier].Applied())
+	assert.Equal(t, fieldpath.APIVersion("apps/v1"), managed[applier].APIVersion())
+	assert.Equal(t, managedFields[1].Time, times[updater])
+
+	roundTrip, err := ManagedFieldsFromSMD(managed, times)
+	require.NoError(t, err)
+	require.Len(t, roundTrip, 2)
+	for idx := range managedFields {
+		assert.Equal(t, managedFields[idx].Manager, roundTrip[idx].Manager)
+		assert.Equal(t, managedFields[idx].Operation, roundTrip[idx].Operation)
+		assert.Equal(t, managedFields[idx].APIVersion, roundTrip[idx].APIVersion)
+		assert.Equal(t, managedFields[idx].Time, roundTrip[idx].Time)
+
+		expected, err := ParseFieldsV1(managedFields[idx].FieldsV1)
+		require.NoError(t, err)
+		actual, err := ParseFieldsV1(roundTrip[idx].FieldsV1)
+		require.NoError(t, err)
+		assert.Equal(t, humanPaths(expected), humanPaths(actual))
+	}
+
+	conflicts := DetectSetConflicts("original-manager", managed)
+	require.Contains(t, conflicts, updater)
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, ".spec.template.spec.containers[name=\"nginx\"].resources.requests", conflicts[updater].String())
+}
+
+func TestDetectFieldConflictsWithFieldSets(t *testing.T) {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecWithoutContainers(),
+			Manager:    "original-manager",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2024-06-17T19:56:27Z")},
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   AppsV1ManagedFieldsMetaAndSpecRequests(),
+			Manager:    "kubectl-client-side-apply",
+			Operation:  "Update",
+			Time:       &metav1.Time{Time: MustParseTime("2044-06-17T19:56:27Z")},
+		},
+	}
+
+	testCases := []struct {
+		desc          string
+		opts          []DetectOption
+		expectedPaths []string
+	}{
+		{
+			desc:          "regexes match the items of the owned containers",
+			expectedPaths: []string{"spec.template.spec.containers[nginx].resources.requests"},
+		},
+		{
+			desc:          "sets match the exact paths only",
+			opts:          []DetectOption{WithFieldSets()},
+			expectedPaths: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			paths := []string{}
+			for _, conflict := range DetectFieldConflicts("original-manager", managedFields, tc.opts...) {
+				paths = append(paths, conflict.Path.Human())
+			}
+			assert.Equal(t, tc.expectedPaths, paths)
+		})
+	}
+}
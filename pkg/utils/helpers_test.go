@@ -173,6 +173,122 @@ func TestDetectExternalManagersAppsV1(t *testing.T) {
 
 }
 
+// managedFieldsEntry returns an apps/v1 entry of the manager, without time when the date is empty
+func managedFieldsEntry(manager string, operation metav1.ManagedFieldsOperationType, fieldsV1 *metav1.FieldsV1, date string) metav1.ManagedFieldsEntry {
+	managedField := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   fieldsV1,
+		Manager:    manager,
+		Operation:  operation,
+	}
+	if date != "" {
+		managedField.Time = &metav1.Time{Time: MustParseTime(date)}
+	}
+	return managedField
+}
+
+func TestDetectUntimedManagedFields(t *testing.T) {
+	testCases := []struct {
+		desc                    string
+		managedFields           []metav1.ManagedFieldsEntry
+		wasOverwritten          bool
+		expectedExternalManager string
+		expectedFields          *metav1.FieldsV1
+	}{
+		{
+			desc: "untimed original-manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kubectl-client-side-apply", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), ""),
+			},
+			wasOverwritten:          true,
+			expectedExternalManager: "kubectl-client-side-apply",
+			expectedFields:          AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			desc: "untimed external manager",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-client-side-apply", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), ""),
+			},
+			wasOverwritten:          false,
+			expectedExternalManager: "kubectl-client-side-apply",
+			expectedFields:          AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			desc: "untimed managers, the latest entry is the last one of the list",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpecLimits(), ""),
+				managedFieldsEntry("kubectl-client-side-apply", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), ""),
+				managedFieldsEntry("original-manager", "Apply", AppsV1ManagedFieldsMetaAndSpec(), ""),
+			},
+			wasOverwritten:          false,
+			expectedExternalManager: "kubectl-client-side-apply",
+			expectedFields:          AppsV1ManagedFieldsMetaAndSpec(),
+		},
+		{
+			desc: "timed external managers are more recent than untimed ones",
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("original-manager", "Update", AppsV1ManagedFieldsMetaAndSpec(), ""),
+				managedFieldsEntry("helm", "Update", AppsV1ManagedFieldsMetaAndSpecLimits(), "2024-06-17T19:56:27Z"),
+				managedFieldsEntry("kubectl-client-side-apply", "Update", AppsV1ManagedFieldsMetaAndSpecRequests(), ""),
+			},
+			wasOverwritten:          true,
+			expectedExternalManager: "helm",
+			expectedFields:          AppsV1ManagedFieldsMetaAndSpec(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			managers := []string{}
+			for _, managedField := range tc.managedFields {
+				managers = append(managers, managedField.Manager)
+			}
+
+			wasOverwritten, manager := DetectExternalManager("original-manager", tc.managedFields)
+			assert.Equal(t, tc.wasOverwritten, wasOverwritten)
+			assert.Equal(t, tc.expectedExternalManager, manager)
+
+			found, fields, _ := DetectManagedFields("original-manager", tc.managedFields)
+			assert.True(t, found)
+			assert.Equal(t, tc.expectedFields, fields)
+
+			// the managed fields are not sorted in place
+			for idx, managedField := range tc.managedFields {
+				assert.Equal(t, managers[idx], managedField.Manager)
+			}
+		})
+	}
+}
+
+func TestDetectFieldConflictsRegexCharacters(t *testing.T) {
+	fieldsV1 := &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:example.com/owner(team":{}}}}`)}
+	managedFields := []metav1.ManagedFieldsEntry{
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   fieldsV1,
+			Manager:    "original-manager",
+			Operation:  "Update",
+		},
+		{
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   fieldsV1,
+			Manager:    "kubectl-annotate",
+			Operation:  "Update",
+		},
+	}
+
+	conflicts := DetectFieldConflicts("original-manager", managedFields)
+	if assert.Len(t, conflicts, 1) {
+		assert.Equal(t, "kubectl-annotate", conflicts[0].ExternalManager)
+		assert.False(t, conflicts[0].Overwritten)
+	}
+}
+
 func TestFieldsV1ToJSONPaths(t *testing.T) {
 	testCases := []struct {
 		desc              string
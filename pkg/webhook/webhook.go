@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"managedfields/pkg/utils"
+)
+
+// Action is what the handler does when a field of the protected manager is taken over
+type Action string
+
+const (
+	// Deny rejects the request
+	Deny Action = "Deny"
+	// Warn admits the request with a warning returned to the client
+	Warn Action = "Warn"
+)
+
+// Handler is a validating admission webhook (AdmissionReview v1) rejecting or warning on updates
+// where a manager, not in the allow list, takes ownership of fields of the protected manager
+type Handler struct {
+	protectedManager string
+	allowedManagers  map[string]struct{}
+	action           Action
+	options          []utils.DetectOption
+}
+
+// NewHandler returns a Handler protecting the fields of protectedManager,
+// the allowed managers may take ownership of them. The options configure the detection
+// of the takeovers, e.g. the writes of WithIgnoreList are never taken over.
+func NewHandler(protectedManager string, allowedManagers []string, action Action, opts ...utils.DetectOption) *Handler {
+	allowed := map[string]struct{}{}
+	for _, manager := range allowedManagers {
+		allowed[manager] = struct{}{}
+	}
+
+	return &Handler{
+		protectedManager: protectedManager,
+		allowedManagers:  allowed,
+		action:           action,
+		options:          opts,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("decoding admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if review.Request == nil {
+		http.Error(w, "admission review without request", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.Review(review.Request)
+	if err != nil {
+		response = &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusBadRequest,
+				Reason:  metav1.StatusReasonBadRequest,
+				Message: err.Error(),
+			},
+		}
+	}
+	response.UID = review.Request.UID
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(admissionv1.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: response,
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("encoding admission review: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// Review compares the managed fields of the old and new objects of the request
+// and returns the admission response, only updates are checked.
+// The managed fields entries that could not be analyzed are returned as warnings,
+// whatever the action, the takeovers of the other entries are still checked.
+func (h *Handler) Review(request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+
+	if request.Operation != admissionv1.Update {
+		return response, nil
+	}
+
+	oldObject, err := utils.ToUnstructured(request.OldObject.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding old object: %w", err)
+	}
+
+	newObject, err := utils.ToUnstructured(request.Object.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding object: %w", err)
+	}
+
+	// the objects, not only their managed fields, to tell the fields a co-owner wrote again from the ones it kept
+	takeovers, err := utils.DetectFieldTakeoversInObjects(h.protectedManager, oldObject, newObject, h.options...)
+	response.Warnings = entryWarnings(err)
+
+	messages := []string{}
+	for _, takeover := range takeovers {
+		if _, allowed := h.allowedManagers[takeover.ExternalManager]; allowed {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s is not allowed to take ownership of %s managed by %s",
+			takeover.ExternalManager, takeover.Path.Human(), takeover.OriginalManager))
+	}
+
+	if len(messages) == 0 {
+		return response, nil
+	}
+
+	if h.action == Warn {
+		response.Warnings = append(response.Warnings, messages...)
+		return response, nil
+	}
+
+	response.Allowed = false
+	response.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: strings.Join(messages, "; "),
+	}
+
+	return response, nil
+}
+
+// Helper function returning a warning per managed fields entry of the error, e.g.
+// "managed fields not analyzed: new managedFields[1] of helm: unsupported fieldsType "FieldsV2""
+func entryWarnings(err error) []string {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	warnings := []string{}
+	for _, e := range errs {
+		warnings = append(warnings, "managed fields not analyzed: "+e.Error())
+	}
+	return warnings
+}
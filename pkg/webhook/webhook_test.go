@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"managedfields/pkg/utils"
+)
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		payload          string
+		action           Action
+		expectedUID      types.UID
+		expectedAllowed  bool
+		expectedMessage  string
+		expectedWarnings []string
+	}{
+		{
+			desc:            "takeover denied",
+			payload:         "takeover.json",
+			action:          Deny,
+			expectedUID:     "6d1f2a5e-0001",
+			expectedAllowed: false,
+			expectedMessage: "kubectl-client-side-apply is not allowed to take ownership of spec.template.spec.containers[nginx].resources.requests managed by original-manager",
+		},
+		{
+			desc:            "takeover warned",
+			payload:         "takeover.json",
+			action:          Warn,
+			expectedUID:     "6d1f2a5e-0001",
+			expectedAllowed: true,
+			expectedWarnings: []string{
+				"kubectl-client-side-apply is not allowed to take ownership of spec.template.spec.containers[nginx].resources.requests managed by original-manager",
+			},
+		},
+		{
+			desc:            "takeover by allowed manager",
+			payload:         "takeover-allowed.json",
+			action:          Deny,
+			expectedUID:     "6d1f2a5e-0002",
+			expectedAllowed: true,
+		},
+		{
+			desc:            "creation is not checked",
+			payload:         "create.json",
+			action:          Deny,
+			expectedUID:     "6d1f2a5e-0003",
+			expectedAllowed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", tc.payload))
+			require.NoError(t, err)
+
+			server := httptest.NewServer(NewHandler("original-manager", []string{"stormforge-optimizer"}, tc.action))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL, "application/json", bytes.NewReader(payload))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			review := admissionv1.AdmissionReview{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&review))
+			require.NotNil(t, review.Response)
+
+			assert.Equal(t, "AdmissionReview", review.Kind)
+			assert.Equal(t, tc.expectedUID, review.Response.UID)
+			assert.Equal(t, tc.expectedAllowed, review.Response.Allowed)
+			assert.Equal(t, tc.expectedWarnings, review.Response.Warnings)
+			if tc.expectedMessage != "" {
+				require.NotNil(t, review.Response.Result)
+				assert.Equal(t, tc.expectedMessage, review.Response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestHandlerIgnoreList(t *testing.T) {
+	payload, err := os.ReadFile(filepath.Join("testdata", "takeover.json"))
+	require.NoError(t, err)
+	review := admissionv1.AdmissionReview{}
+	require.NoError(t, json.Unmarshal(payload, &review))
+
+	ignore, err := utils.NewIgnoreList(utils.IgnoreRule{Managers: []string{"kubectl-client-side-apply"}})
+	require.NoError(t, err)
+
+	response, err := NewHandler("original-manager", nil, Deny, utils.WithIgnoreList(ignore)).Review(review.Request)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+}
+
+func TestHandlerEntryErrors(t *testing.T) {
+	payload, err := os.ReadFile(filepath.Join("testdata", "takeover.json"))
+	require.NoError(t, err)
+	review := admissionv1.AdmissionReview{}
+	require.NoError(t, json.Unmarshal(payload, &review))
+
+	// an entry of the new object with an unsupported fields type
+	newObject := metav1.PartialObjectMetadata{}
+	require.NoError(t, json.Unmarshal(review.Request.Object.Raw, &newObject))
+	newObject.ManagedFields = append(newObject.ManagedFields, metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV2",
+		FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecLimits(),
+		Manager:    "helm",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: utils.MustParseTime("2044-06-18T19:56:27Z")},
+	})
+	review.Request.Object.Raw, err = json.Marshal(newObject)
+	require.NoError(t, err)
+
+	response, err := NewHandler("original-manager", nil, Deny).Review(review.Request)
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, []string{`managed fields not analyzed: new managedFields[2] of helm: unsupported fieldsType "FieldsV2"`}, response.Warnings)
+}
+
+func TestHandlerCoOwner(t *testing.T) {
+	object := func(requestsCPU, annotation string, managedFields ...metav1.ManagedFieldsEntry) runtime.RawExtension {
+		raw, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":          "nginx",
+				"namespace":     "default",
+				"annotations":   map[string]interface{}{"note": annotation},
+				"managedFields": managedFields,
+			},
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":      "nginx",
+					"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": requestsCPU}},
+				}},
+			}}},
+		})
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: raw}
+	}
+	original := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpec(),
+		Manager:    "original-manager",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-17T19:56:27Z")},
+	}
+	coOwner := metav1.ManagedFieldsEntry{
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   utils.AppsV1ManagedFieldsMetaAndSpecRequests(),
+		Manager:    "kubectl-client-side-apply",
+		Operation:  "Update",
+		Time:       &metav1.Time{Time: utils.MustParseTime("2024-06-18T19:56:27Z")},
+	}
+	// the co-owner writing again, its entry gets a new time
+	coOwnerAgain := *coOwner.DeepCopy()
+	coOwnerAgain.Time = &metav1.Time{Time: utils.MustParseTime("2024-06-19T19:56:27Z")}
+
+	testCases := []struct {
+		desc            string
+		newObject       runtime.RawExtension
+		expectedAllowed bool
+	}{
+		{
+			desc:            "co-owner writing an unrelated annotation",
+			newObject:       object("250m", "updated", original, coOwnerAgain),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "co-owner writing the requests again",
+			newObject:       object("500m", "", original, coOwnerAgain),
+			expectedAllowed: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.desc), func(t *testing.T) {
+			response, err := NewHandler("original-manager", nil, Deny).Review(&admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				OldObject: object("250m", "", original, coOwner),
+				Object:    tc.newObject,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedAllowed, response.Allowed)
+		})
+	}
+}
+
+func TestHandlerBadRequest(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"apiVersion":`)))
+
+	NewHandler("original-manager", nil, Deny).ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}